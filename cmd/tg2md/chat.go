@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/assets"
+	"github.com/grigoriizhovtun/tg2md/internal/converter"
+	"github.com/grigoriizhovtun/tg2md/internal/logger"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/replycache"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+	"github.com/grigoriizhovtun/tg2md/internal/state"
+	"github.com/grigoriizhovtun/tg2md/internal/threads"
+	"github.com/grigoriizhovtun/tg2md/internal/writer"
+)
+
+// chatConverter writes the messages of one chat into its group directory:
+// the group files, a subdirectory per forum topic and the thread views.
+type chatConverter struct {
+	name     string
+	groupDir string
+	opts     options
+	log      *logger.Logger
+	conv     *converter.Converter
+	stats    chatStats
+
+	// last is the state of the previous run in incremental mode, resumed
+	// its files by absolute path; newest is the newest written message
+	last    *state.State
+	resumed map[string]writer.FileState
+	newest  *parser.Message
+
+	// importers place attachments, one per export folder since merged
+	// exports each have their own
+	importers map[string]*assets.Importer
+
+	// w writes the group files; forum topics get their own writers,
+	// created on first use, within the same sort budget
+	w              *writer.Writer
+	budget         *writer.SortBudget
+	topics         map[int64]*writer.Writer
+	topicTitles    map[int64]string
+	usedTopicNames map[string]bool
+
+	// threads collects reply threads per output directory
+	threads map[*writer.Writer]*threads.Builder
+}
+
+// convertChat writes all messages of one chat into its group directory.
+// replies may be pre-filled by a pre-pass over the export, which resolves
+// replies to messages that appear later or fail to convert.
+func convertChat(chatName string, messages <-chan parser.ParseResult, replies *replycache.Cache,
+	outputPath string, opts options) (chatStats, error) {
+	c, err := newChatConverter(chatName, replies, outputPath, opts)
+	if err != nil {
+		return chatStats{}, err
+	}
+	defer c.close()
+
+	for result := range messages {
+		c.convert(result)
+	}
+	if err := replies.Err(); err != nil {
+		c.log.LogError(0, err.Error())
+	}
+
+	if err := c.finish(); err != nil {
+		return c.stats, err
+	}
+	c.report()
+	return c.stats, nil
+}
+
+// newChatConverter creates the group directory with its log and the
+// writer of the group files.
+func newChatConverter(chatName string, replies *replycache.Cache, outputPath string,
+	opts options) (*chatConverter, error) {
+	groupDir := filepath.Join(outputPath, sanitizer.SanitizeName(chatName))
+	if err := os.MkdirAll(groupDir, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	log, err := logger.New(filepath.Join(groupDir, "errors.log"))
+	if err != nil {
+		return nil, fmt.Errorf("init logger: %w", err)
+	}
+	log.Info("%s", opts.locale.T("console.group", chatName))
+
+	c := &chatConverter{
+		name:     chatName,
+		groupDir: groupDir,
+		opts:     opts,
+		log:      log,
+		newest:   &parser.Message{},
+		importers: map[string]*assets.Importer{
+			opts.exportDir: assets.New(opts.exportDir, groupDir, opts.media),
+		},
+		budget:         writer.NewSortBudget(writer.DefaultSortBudget),
+		topics:         make(map[int64]*writer.Writer),
+		topicTitles:    make(map[int64]string),
+		usedTopicNames: map[string]bool{assets.Dir: true},
+		threads:        make(map[*writer.Writer]*threads.Builder),
+	}
+
+	// An incremental run only writes messages after the last run's and
+	// appends them to its files
+	if opts.incremental {
+		if c.last, err = state.Load(groupDir); err != nil {
+			log.Close()
+			return nil, err
+		}
+		for _, rel := range c.last.Edited(groupDir) {
+			log.Info("%s", opts.locale.T("console.edited", rel))
+		}
+		c.resumed = c.last.Resume(groupDir)
+	}
+
+	if c.w, err = writer.New(outputPath, chatName); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("init writer: %w", err)
+	}
+	c.w.SetSortBudget(c.budget)
+	if err := c.setupWriter(c.w, converter.FileView{Chat: chatName}); err != nil {
+		c.close()
+		return nil, fmt.Errorf("init writer: %w", err)
+	}
+
+	convOpts := converter.Options{
+		EmbedMedia: opts.media != assets.ModeNone,
+		MediaLink:  c.mediaLink,
+		ShowEdits:  opts.edits,
+		Reactions:  opts.reactions,
+		Lines:      opts.lines,
+		Links:      opts.links,
+		Replies:    replies,
+		Anchors:    opts.anchors,
+		Templates:  opts.templates,
+		Locale:     opts.locale,
+	}
+	if opts.anchors {
+		convOpts.ReplyLink = c.replyLink
+	}
+	if c.last != nil {
+		convOpts.References = c.last.References
+	}
+	c.conv = converter.NewWithOptions(convOpts)
+	return c, nil
+}
+
+// close releases the writers, thread spools and the log. Files are only
+// written by finish.
+func (c *chatConverter) close() {
+	for _, tb := range c.threads {
+		tb.Close()
+	}
+	for _, tw := range c.topics {
+		tw.Close()
+	}
+	c.w.Close()
+	c.log.Close()
+}
+
+// setupWriter sets how w splits files, naming months in the locale with
+// -localized-names, renders their headers and footers from the templates
+// and which files of an earlier run it appends to.
+func (c *chatConverter) setupWriter(w *writer.Writer, file converter.FileView) error {
+	split := c.opts.split
+	if c.opts.localizedNames {
+		split.MonthName = c.opts.locale.Month
+	}
+	if err := w.SetSplit(split); err != nil {
+		return err
+	}
+	templates := c.opts.templates
+	w.SetFrame(func(period string, start time.Time) (string, error) {
+		file.Period, file.Start = period, start
+		return templates.Header(file)
+	}, func(period string, start time.Time) (string, error) {
+		file.Period, file.Start = period, start
+		return templates.Footer(file)
+	})
+	w.Resume(c.resumed)
+	return nil
+}
+
+// convert converts one message and writes it to the files of its topic
+// and the thread view. Failures are logged and the message skipped.
+func (c *chatConverter) convert(result parser.ParseResult) {
+	c.stats.total++
+	if result.Error != nil {
+		c.log.LogError(0, result.Error.Error())
+		c.stats.skipped++
+		return
+	}
+	msg := result.Message
+
+	// Place attachments; missing files keep their text label
+	exportDir := c.opts.exportDir
+	if result.ExportDir != "" {
+		exportDir = result.ExportDir
+	}
+	if c.importers[exportDir] == nil {
+		c.importers[exportDir] = assets.New(exportDir, c.groupDir, c.opts.media)
+	}
+	for _, err := range c.importers[exportDir].Import(msg) {
+		c.log.LogError(msg.ID, err.Error())
+	}
+
+	formatted, timestamp, err := c.conv.ConvertMessage(msg)
+	if err != nil {
+		c.log.LogError(msg.ID, err.Error())
+		c.stats.skipped++
+		return
+	}
+	target, err := c.writerFor(msg.ID)
+	if err != nil {
+		c.log.LogError(msg.ID, err.Error())
+		c.stats.skipped++
+		return
+	}
+
+	if c.last != nil && msg.ID <= c.last.LastID {
+		// Written by an earlier run, converted only for replies, links
+		// and threads
+		target.MarkWritten(msg.ID, timestamp)
+		c.stats.earlier++
+	} else {
+		if err := target.WriteMessage(formatted, timestamp); err != nil {
+			c.log.LogError(msg.ID, err.Error())
+			c.stats.skipped++
+			return
+		}
+		target.MarkMessage(msg.ID)
+		for _, ref := range c.conv.References() {
+			target.AddReference(ref.Label, ref.URL)
+		}
+		if msg.ID > c.newest.ID {
+			c.newest = msg
+		}
+		c.stats.processed++
+	}
+
+	if c.opts.threads {
+		if err := c.addToThread(target, msg, timestamp, formatted); err != nil {
+			c.log.LogError(msg.ID, err.Error())
+		}
+	}
+}
+
+// writerFor picks the writer of a message's topic, General topic goes to
+// the group files.
+func (c *chatConverter) writerFor(msgID int64) (*writer.Writer, error) {
+	topicID, title, ok := c.conv.Topic(msgID)
+	if !ok {
+		return c.w, nil
+	}
+	if c.topics[topicID] == nil {
+		name := uniqueName(title, topicID, "topic", c.usedTopicNames)
+		tw, err := writer.New(c.groupDir, name)
+		if err != nil {
+			return nil, err
+		}
+		tw.SetSortBudget(c.budget)
+		if err := c.setupWriter(tw, converter.FileView{Chat: c.name, Topic: title}); err != nil {
+			tw.Close()
+			return nil, err
+		}
+		c.topics[topicID] = tw
+		c.topicTitles[topicID] = name
+	}
+	return c.topics[topicID], nil
+}
+
+// linkDir returns dir, or when empty the directory of the file a message
+// of w sent at the given time is written to.
+func linkDir(w *writer.Writer, sent time.Time, dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Dir(w.FileFor(sent))
+}
+
+// replyLink links a reply to the file its target was written to, relative
+// to dir or the directory of the file of the replying message.
+func (c *chatConverter) replyLink(from, to int64, sent time.Time, dir string) (string, bool) {
+	source, err := c.writerFor(from)
+	if err != nil {
+		return "", false
+	}
+	holder, err := c.writerFor(to)
+	if err != nil {
+		return "", false
+	}
+	file, ok := holder.Locate(to)
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(linkDir(source, sent, dir), file)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel) + "#" + converter.AnchorName(to), true
+}
+
+// mediaLink links an imported attachment, stored relative to the group
+// directory, from dir or the directory of the file of its message.
+func (c *chatConverter) mediaLink(from int64, sent time.Time, dir, p string) string {
+	target, err := c.writerFor(from)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(linkDir(target, sent, dir), filepath.Join(c.groupDir, filepath.FromSlash(p)))
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// addToThread records a converted message for the thread view of its
+// output directory. Replies to a forum topic root only mark the topic and
+// do not join the topic into one thread.
+func (c *chatConverter) addToThread(target *writer.Writer, msg *parser.Message, timestamp time.Time,
+	formatted string) error {
+	// The thread view lives in the output directory, which file name
+	// patterns may nest message files below
+	if dir := target.GetOutputDir(); dir != filepath.Dir(target.FileFor(timestamp)) {
+		var err error
+		if formatted, err = c.conv.Relink(dir); err != nil {
+			return err
+		}
+	}
+
+	tb := c.threads[target]
+	if tb == nil {
+		tb = threads.New(target.GetOutputDir(), c.opts.locale)
+		c.threads[target] = tb
+	}
+
+	var parent int64
+	if msg.ReplyToMsgID != nil {
+		parent = *msg.ReplyToMsgID
+		if topicID, _, ok := c.conv.Topic(msg.ID); ok && topicID == parent {
+			parent = 0
+		}
+	}
+	if err := tb.Add(msg.ID, parent, timestamp, formatted); err != nil {
+		return err
+	}
+	for _, ref := range c.conv.References() {
+		tb.AddReference(ref.Label, ref.URL)
+	}
+	return nil
+}
+
+// finish writes the files of the group and topic writers, the state for
+// the next incremental run and the thread views.
+func (c *chatConverter) finish() error {
+	// Files are written when their writer is closed
+	for _, tw := range c.topics {
+		if err := tw.Close(); err != nil {
+			return fmt.Errorf("write topic files: %w", err)
+		}
+	}
+	if err := c.w.Close(); err != nil {
+		return fmt.Errorf("write files: %w", err)
+	}
+	if c.last != nil {
+		if err := c.saveState(); err != nil {
+			return err
+		}
+	}
+
+	for tw, tb := range c.threads {
+		dir := tw.GetOutputDir()
+		count, err := tb.Write(filepath.Join(dir, filepath.Base(dir)+"_threads.md"))
+		if err != nil {
+			c.log.LogError(0, err.Error())
+			continue
+		}
+		if count > 0 {
+			c.log.Info("%s", c.opts.locale.T("console.threads", count, filepath.Base(dir)))
+			c.stats.files++
+		}
+	}
+	return nil
+}
+
+// saveState records the files written by the group and topic writers, the
+// labels of reference links and the newest written message for the next
+// incremental run.
+func (c *chatConverter) saveState() error {
+	s := c.last
+	if err := s.Update(c.groupDir, c.w.Files()); err != nil {
+		return err
+	}
+	for _, tw := range c.topics {
+		if err := s.Update(c.groupDir, tw.Files()); err != nil {
+			return err
+		}
+	}
+	s.References = c.conv.ReferenceLabels()
+	if c.newest.ID > s.LastID {
+		s.LastID, s.LastDate = c.newest.ID, c.newest.Date
+	}
+	return s.Save(c.groupDir)
+}
+
+// report prints the message counts of the chat by file and topic.
+func (c *chatConverter) report() {
+	loc := c.opts.locale
+	c.log.Info("%s", loc.T("console.messages", c.stats.total))
+	if c.last != nil {
+		c.log.Info("%s", loc.T("console.earlier", c.stats.earlier))
+	}
+
+	// Print breakdown by file, unsplit output has no period
+	for period, count := range c.w.GetStats() {
+		if period == "" {
+			period = c.name
+		}
+		if count > 0 {
+			c.log.Info("%s", loc.T("console.period", period, loc.N(count, "messages")))
+		}
+	}
+
+	c.stats.files += c.w.GetFileCount()
+	c.stats.unknownEntities = c.conv.UnknownEntities()
+	topicIDs := make([]int64, 0, len(c.topics))
+	for topicID := range c.topics {
+		topicIDs = append(topicIDs, topicID)
+	}
+	slices.Sort(topicIDs)
+
+	for _, topicID := range topicIDs {
+		tw := c.topics[topicID]
+		count := 0
+		for _, n := range tw.GetStats() {
+			count += n
+		}
+		c.log.Info("%s", loc.T("console.topic", c.topicTitles[topicID], loc.N(count, "messages")))
+		c.stats.files += tw.GetFileCount()
+	}
+}
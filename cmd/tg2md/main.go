@@ -1,126 +1,501 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/grigoriizhovtun/tg2md/internal/assets"
 	"github.com/grigoriizhovtun/tg2md/internal/converter"
+	"github.com/grigoriizhovtun/tg2md/internal/htmlparser"
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
 	"github.com/grigoriizhovtun/tg2md/internal/logger"
+	"github.com/grigoriizhovtun/tg2md/internal/merge"
 	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/replycache"
 	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+	"github.com/grigoriizhovtun/tg2md/internal/state"
 	"github.com/grigoriizhovtun/tg2md/internal/writer"
 )
 
+// source is a single-chat export, either JSON or HTML.
+type source interface {
+	GetChatInfo() (name, chatType string, err error)
+	StreamMessages() <-chan parser.ParseResult
+	Close() error
+}
+
+// options holds command-line settings shared by all chats of a run.
+type options struct {
+	// exportDir is the folder media paths in the export are relative to
+	exportDir string
+	media     assets.Mode
+	edits     bool
+	reactions converter.ReactionMode
+	lines     converter.LineMode
+	links     converter.LinkMode
+	// prepass indexes the whole export first so every reply resolves
+	prepass bool
+	// replyBudget caps memory for reply previews, in bytes
+	replyBudget int
+	// anchors adds message anchors and links replies to them
+	anchors bool
+	// threads also writes reply threads next to the monthly files
+	threads bool
+	// templates render messages and file headers and footers
+	templates *converter.Templates
+	// split divides each chat into files
+	split writer.SplitOptions
+	// locale labels messages and words console output
+	locale *locale.Locale
+	// localizedNames names months in file names in the locale, not English
+	localizedNames bool
+	// incremental appends messages newer than the last run to its files
+	incremental bool
+}
+
+// chatStats holds conversion results for a single chat.
+type chatStats struct {
+	total     int
+	processed int
+	skipped   int
+	files     int
+	// earlier counts messages written by earlier incremental runs
+	earlier int
+	// unknownEntities counts text entity types kept as plain text
+	unknownEntities map[string]int
+}
+
+// add accumulates stats of another chat.
+func (s *chatStats) add(other chatStats) {
+	s.total += other.total
+	s.processed += other.processed
+	s.skipped += other.skipped
+	s.earlier += other.earlier
+	s.files += other.files
+	for entityType, count := range other.unknownEntities {
+		if s.unknownEntities == nil {
+			s.unknownEntities = make(map[string]int)
+		}
+		s.unknownEntities[entityType] += count
+	}
+}
+
+// reportUnknownEntities warns about entity types without a Markdown mapping.
+func reportUnknownEntities(console *logger.Logger, loc *locale.Locale, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	types := make([]string, 0, len(counts))
+	for entityType := range counts {
+		types = append(types, entityType)
+	}
+	slices.Sort(types)
+
+	parts := make([]string, len(types))
+	for i, entityType := range types {
+		parts[i] = fmt.Sprintf("%s (%d)", entityType, counts[entityType])
+	}
+	console.Warning("%s", loc.T("console.unknown_entities", strings.Join(parts, ", ")))
+}
+
 func main() {
 	// Parse arguments
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: tg2md <input.json> [output_path]")
+	mediaMode := flag.String("media", string(assets.ModeNone),
+		"place referenced media into assets/: none, copy or link")
+	showEdits := flag.Bool("edits", true, "mark edited messages with the edit time")
+	reactionMode := flag.String("reactions", string(converter.ReactionsSummary),
+		"render reactions: none, summary or full (with recent reactors)")
+	lineMode := flag.String("lines", string(converter.LinesRaw),
+		"line breaks inside messages: raw, break (hard breaks) or indent")
+	prepass := flag.Bool("prepass", false,
+		"read the export twice to resolve replies to any message")
+	replyCacheMB := flag.Int("reply-cache-mb", 64,
+		"memory for reply previews in MB, the rest is kept on disk")
+	anchors := flag.Bool("anchors", false,
+		"add an anchor to every message and link replies to the original")
+	threadView := flag.Bool("threads", false,
+		"also write reply threads to <chat>_threads.md")
+	templatePath := flag.String("template", "",
+		"text/template file for messages and month-file header and footer")
+	linkMode := flag.String("links", string(converter.LinksURL),
+		"render links: inline, url (address only), reference or text")
+	split := flag.String("split", string(writer.SplitMonth),
+		"divide chats into files by day, week, month, quarter, year, none, size or count")
+	splitSizeMB := flag.Int("split-size", 1, "file size limit in MB for -split size")
+	splitCount := flag.Int("split-count", 1000, "messages per file for -split count")
+	dayStart := flag.Int("day-start", 0,
+		"hour (0-23) at which a day begins for period splits, so late night messages stay with the previous day")
+	namePattern := flag.String("name-pattern", writer.DefaultPattern,
+		"file name pattern, e.g. {chat}_{yyyy}-{mm}.md or {yyyy}/{mm}.md; placeholders: {chat} {period} {yyyy} {mm} {dd} {month} {ww} {q} {n}")
+	incremental := flag.Bool("incremental", false,
+		"append messages newer than the last run to its files, tracked in "+state.FileName+" in the chat directory")
+	mergeExports := flag.Bool("merge", false,
+		"merge several JSON exports of one chat, matched by chat id; the last argument is the output path")
+	localeName := flag.String("locale", "ru",
+		"language of labels and console output: "+strings.Join(locale.Names(), " or "))
+	localizedNames := flag.Bool("localized-names", false,
+		"name months in file names in the -locale language instead of English")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(),
+			"Usage: tg2md [options] <input.json|export_dir|messages.html> [output_path]\n"+
+				"       tg2md -merge [options] <a.json> <b.json>... <output_path>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || *mergeExports && flag.NArg() < 3 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	inputFile := os.Args[1]
+	inputFile := flag.Arg(0)
 	outputPath := "."
-	if len(os.Args) >= 3 {
-		outputPath = os.Args[2]
+	if flag.NArg() >= 2 {
+		outputPath = flag.Arg(1)
+	}
+	if *mergeExports {
+		outputPath = flag.Arg(flag.NArg() - 1)
+	}
+
+	var opts options
+	var err error
+	if opts.media, err = assets.ParseMode(*mediaMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.reactions, err = converter.ParseReactionMode(*reactionMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.lines, err = converter.ParseLineMode(*lineMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.links, err = converter.ParseLinkMode(*linkMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.split.Split, err = writer.ParseSplit(*split); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.split.MaxBytes = *splitSizeMB << 20
+	opts.split.MaxMessages = *splitCount
+	opts.split.DayStart = *dayStart
+	opts.split.Pattern = *namePattern
+	if err := opts.split.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	if *incremental && (opts.split.Split == writer.SplitSize || opts.split.Split == writer.SplitCount) {
+		fmt.Fprintf(os.Stderr, "Error: -incremental needs a calendar split, not %s\n", opts.split.Split)
+		os.Exit(1)
+	}
+	opts.incremental = *incremental
+	opts.localizedNames = *localizedNames
+	if opts.locale, err = locale.Get(*localeName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.edits = *showEdits
+	opts.prepass = *prepass
+	opts.anchors = *anchors
+	opts.threads = *threadView
+	opts.templates = converter.DefaultTemplates(opts.locale)
+	if *templatePath != "" {
+		if opts.templates, err = converter.LoadTemplates(*templatePath, opts.locale); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	opts.replyBudget = *replyCacheMB << 20
 
 	// Run conversion
-	if err := run(inputFile, outputPath); err != nil {
+	if *mergeExports {
+		err = runMerge(flag.Args()[:flag.NArg()-1], outputPath, opts)
+	} else {
+		err = run(inputFile, outputPath, opts)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(inputFile, outputPath string) error {
+func run(inputFile, outputPath string, opts options) error {
 	// Validate input file exists
-	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
+	info, err := os.Stat(inputFile)
+	if os.IsNotExist(err) {
 		return fmt.Errorf("file not found: %s", inputFile)
 	}
 
+	// Media paths are relative to the folder holding the export
+	opts.exportDir = filepath.Dir(inputFile)
+	if err == nil && info.IsDir() {
+		opts.exportDir = inputFile
+	}
+
+	console := logger.NewConsole()
+	console.Info("%s", opts.locale.T("console.loading", inputFile))
+
 	// Create parser
-	p, err := parser.New(inputFile)
-	if err != nil {
-		return fmt.Errorf("open file: %w", err)
+	var src source
+	if htmlparser.IsExport(inputFile) {
+		hp, err := htmlparser.New(inputFile)
+		if err != nil {
+			return fmt.Errorf("open export: %w", err)
+		}
+		defer hp.Close()
+		src = hp
+	} else {
+		p, err := parser.New(inputFile)
+		if err != nil {
+			return fmt.Errorf("open file: %w", err)
+		}
+		defer p.Close()
+
+		// Full-account exports nest every chat under chats.list
+		account, err := p.IsAccountExport()
+		if err != nil {
+			return fmt.Errorf("detect export layout: %w", err)
+		}
+		if account {
+			return runAccount(p, inputFile, outputPath, opts, console)
+		}
+		src = p
+	}
+
+	replies := replycache.New(outputPath, opts.replyBudget)
+	defer replies.Close()
+	if opts.prepass {
+		console.Info("%s", opts.locale.T("console.indexing"))
+		if err := indexChat(inputFile, replies, opts.locale); err != nil {
+			return fmt.Errorf("index messages: %w", err)
+		}
 	}
-	defer p.Close()
 
 	// Get chat info
-	chatName, _, err := p.GetChatInfo()
+	chatName, _, err := src.GetChatInfo()
 	if err != nil {
 		return fmt.Errorf("parse chat info: %w", err)
 	}
 
-	// Sanitize group name and create output directory
-	sanitizedName := sanitizer.SanitizeName(chatName)
-	groupDir := filepath.Join(outputPath, sanitizedName)
-
-	if err := os.MkdirAll(groupDir, 0755); err != nil {
-		return fmt.Errorf("create directory: %w", err)
+	stats, err := convertChat(chatName, src.StreamMessages(), replies, outputPath, opts)
+	if err != nil {
+		return err
 	}
 
-	// Initialize logger
-	log, err := logger.New(filepath.Join(groupDir, "errors.log"))
-	if err != nil {
-		return fmt.Errorf("init logger: %w", err)
+	reportUnknownEntities(console, opts.locale, stats.unknownEntities)
+	console.Success("%s", opts.locale.T("console.done",
+		opts.locale.N(stats.files, "files"), opts.locale.N(stats.skipped, "messages")))
+
+	return nil
+}
+
+// runMerge converts several JSON exports of one chat, matched by chat ID,
+// as a single chat and reports gaps and conflicts between them. Later
+// exports are taken as newer, and the chat is named as in the last one.
+func runMerge(inputFiles []string, outputPath string, opts options) error {
+	console := logger.NewConsole()
+
+	var inputs []merge.Input
+	var chatName string
+	var chatID int64
+	for _, inputFile := range inputFiles {
+		console.Info("%s", opts.locale.T("console.loading", inputFile))
+		if htmlparser.IsExport(inputFile) {
+			return fmt.Errorf("merge needs JSON exports: %s", inputFile)
+		}
+		p, err := parser.New(inputFile)
+		if err != nil {
+			return fmt.Errorf("open file: %w", err)
+		}
+		defer p.Close()
+
+		account, err := p.IsAccountExport()
+		if err != nil {
+			return fmt.Errorf("detect export layout: %w", err)
+		}
+		if account {
+			return fmt.Errorf("merge needs single-chat exports: %s", inputFile)
+		}
+		name, _, err := p.GetChatInfo()
+		if err != nil {
+			return fmt.Errorf("parse chat info: %w", err)
+		}
+		switch {
+		case p.ChatID() == 0:
+			return fmt.Errorf("no chat id to match in %s", inputFile)
+		case chatID != 0 && p.ChatID() != chatID:
+			return fmt.Errorf("%s is an export of chat %d, not %d", inputFile, p.ChatID(), chatID)
+		}
+		chatName, chatID = name, p.ChatID()
+		inputs = append(inputs, merge.Input{Path: inputFile, Dir: filepath.Dir(inputFile), Messages: p.StreamMessages()})
 	}
-	defer log.Close()
+	opts.exportDir = inputs[0].Dir
 
-	log.Info("Загрузка: %s", inputFile)
-	log.Info("Группа: %s", chatName)
+	replies := replycache.New(outputPath, opts.replyBudget)
+	defer replies.Close()
+	if opts.prepass {
+		console.Info("%s", opts.locale.T("console.indexing"))
+		for _, inputFile := range inputFiles {
+			if err := indexChat(inputFile, replies, opts.locale); err != nil {
+				return fmt.Errorf("index messages: %w", err)
+			}
+		}
+	}
 
-	// Initialize converter and writer
-	conv := converter.New()
-	w, err := writer.New(outputPath, chatName)
+	merger := merge.New(inputs)
+	stats, err := convertChat(chatName, merger.Stream(), replies, outputPath, opts)
 	if err != nil {
-		return fmt.Errorf("init writer: %w", err)
+		return err
 	}
-	defer w.Close()
 
-	// Process messages
-	var totalCount, processedCount, skippedCount int
+	report := merger.Report()
+	for _, c := range report.Inputs {
+		if c.Messages == 0 {
+			continue
+		}
+		console.Info("%s", opts.locale.T("console.merge_input", c.Path, c.First, c.Last, c.FirstDate, c.LastDate))
+	}
+	console.Info("%s", opts.locale.T("console.merge", len(inputs), report.Duplicates, report.Edits, len(report.Conflicts)))
+	for _, g := range report.Gaps {
+		console.Warning("%s", opts.locale.T("console.merge_gap", g.First, g.FirstDate, g.Last, g.LastDate))
+	}
+	for _, c := range report.Conflicts {
+		console.Warning("%s", opts.locale.T("console.merge_conflict", c.ID, strings.Join(c.Paths, ", "), c.Kept))
+	}
 
-	for result := range p.StreamMessages() {
-		totalCount++
+	reportUnknownEntities(console, opts.locale, stats.unknownEntities)
+	console.Success("%s", opts.locale.T("console.done",
+		opts.locale.N(stats.files, "files"), opts.locale.N(stats.skipped, "messages")))
+	return nil
+}
 
-		if result.Error != nil {
-			log.LogError(0, result.Error.Error())
-			skippedCount++
-			continue
+// runAccount converts every chat of a full-account export into its own
+// directory and prints a combined summary.
+func runAccount(p *parser.Parser, inputFile, outputPath string, opts options, console *logger.Logger) error {
+	var summary chatStats
+	chatCount := 0
+	usedNames := make(map[string]bool)
+
+	// Indexed chats are parked on disk until their turn
+	indexed := make(map[int64]*replycache.Cache)
+	defer func() {
+		for _, replies := range indexed {
+			replies.Close()
+		}
+	}()
+	if opts.prepass {
+		console.Info("%s", opts.locale.T("console.indexing"))
+		if err := indexAccount(inputFile, outputPath, opts.replyBudget, opts.locale, indexed); err != nil {
+			return fmt.Errorf("index messages: %w", err)
 		}
+	}
 
-		msg := result.Message
+	for chat := range p.StreamChats() {
+		if chat.Error != nil {
+			return fmt.Errorf("parse chats: %w", chat.Error)
+		}
 
-		// Convert message
-		formatted, timestamp, err := conv.ConvertMessage(msg)
-		if err != nil {
-			log.LogError(msg.ID, err.Error())
-			skippedCount++
-			continue
+		chatName := uniqueName(chat.Info.Name, chat.Info.ID, "chat", usedNames)
+		replies := indexed[chat.Info.ID]
+		if replies == nil {
+			replies = replycache.New(outputPath, opts.replyBudget)
 		}
+		delete(indexed, chat.Info.ID)
 
-		// Write to file
-		if err := w.WriteMessage(formatted, timestamp); err != nil {
-			log.LogError(msg.ID, err.Error())
-			skippedCount++
-			continue
+		stats, err := convertChat(chatName, chat.Messages, replies, outputPath, opts)
+		replies.Close()
+		if err != nil {
+			// Closing the parser stops the rest of the stream
+			return fmt.Errorf("chat %q: %w", chatName, err)
 		}
 
-		processedCount++
+		chatCount++
+		summary.add(stats)
 	}
 
-	// Print stats
-	log.Info("Найдено сообщений: %d", totalCount)
+	console.Info("%s", opts.locale.T("console.chats", chatCount))
+	console.Info("%s", opts.locale.T("console.messages", summary.total))
+	reportUnknownEntities(console, opts.locale, summary.unknownEntities)
+	console.Success("%s", opts.locale.T("console.done_account", opts.locale.N(chatCount, "chats"),
+		opts.locale.N(summary.files, "files"), opts.locale.N(summary.skipped, "messages")))
 
-	// Print monthly breakdown
-	for month, count := range w.GetStats() {
-		if count > 0 {
-			log.Info("Обработка: %s (%d сообщений)", month, count)
+	return nil
+}
+
+// indexChat reads a single-chat export and stores the reply preview of
+// every message.
+func indexChat(inputFile string, replies *replycache.Cache, loc *locale.Locale) error {
+	var src source
+	if htmlparser.IsExport(inputFile) {
+		hp, err := htmlparser.New(inputFile)
+		if err != nil {
+			return err
 		}
+		src = hp
+	} else {
+		p, err := parser.New(inputFile)
+		if err != nil {
+			return err
+		}
+		src = p
 	}
+	defer src.Close()
 
-	log.Success("Готово! Создано %d файлов, пропущено %d сообщений",
-		w.GetFileCount(), skippedCount)
+	for result := range src.StreamMessages() {
+		// Broken messages are reported by the main pass
+		if result.Error == nil {
+			replies.Put(result.Message.ID, converter.Preview(result.Message, loc))
+		}
+	}
+	return nil
+}
+
+// indexAccount reads a full-account export and stores reply previews per
+// chat ID. Each chat's previews are spilled to disk once it is indexed.
+func indexAccount(inputFile, outputPath string, budget int, loc *locale.Locale,
+	indexed map[int64]*replycache.Cache) error {
+	p, err := parser.New(inputFile)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
 
+	for chat := range p.StreamChats() {
+		if chat.Error != nil {
+			return chat.Error
+		}
+		replies := replycache.New(outputPath, budget)
+		indexed[chat.Info.ID] = replies
+		for result := range chat.Messages {
+			if result.Error == nil {
+				replies.Put(result.Message.ID, converter.Preview(result.Message, loc))
+			}
+		}
+		if err := replies.Spill(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// uniqueName picks a unique directory-safe name for a chat or topic.
+// Unnamed entries (saved messages, deleted accounts) become "<kind> <id>",
+// and entries whose sanitized name is already taken get their ID appended.
+func uniqueName(name string, id int64, kind string, used map[string]bool) string {
+	if sanitizer.SanitizeName(name) == "" {
+		name = fmt.Sprintf("%s %d", kind, id)
+	}
+	if used[sanitizer.SanitizeName(name)] {
+		name = fmt.Sprintf("%s %d", name, id)
+	}
+	used[sanitizer.SanitizeName(name)] = true
+	return name
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/assets"
+	"github.com/grigoriizhovtun/tg2md/internal/converter"
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+)
+
+// testOptions returns the command-line defaults.
+func testOptions() options {
+	loc := locale.Default()
+	return options{
+		edits:       true,
+		reactions:   converter.ReactionsSummary,
+		lines:       converter.LinesRaw,
+		links:       converter.LinksURL,
+		replyBudget: 1 << 20,
+		templates:   converter.DefaultTemplates(loc),
+		locale:      loc,
+	}
+}
+
+// writeExport writes a single-chat JSON export with the given files and
+// returns its path.
+func writeExport(t *testing.T, export string, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	path := filepath.Join(dir, "result.json")
+	if err := os.WriteFile(path, []byte(export), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func readOutput(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return string(content)
+}
+
+func TestRun_TopicMediaLinks(t *testing.T) {
+	input := writeExport(t, `{"name": "Forum", "type": "private_supergroup", "id": 9, "messages": [
+		{"id": 1, "type": "message", "date": "2024-01-15T10:00:00", "from": "Иван", "photo": "photos/p0.jpg", "text": ""},
+		{"id": 2, "type": "service", "date": "2024-01-15T10:01:00", "actor": "Иван", "action": "topic_created", "title": "Dev", "text": ""},
+		{"id": 3, "type": "message", "date": "2024-01-15T10:02:00", "from": "Мария", "reply_to_message_id": 2, "photo": "photos/p1.jpg", "text": ""}
+	]}`, "photos/p0.jpg", "photos/p1.jpg")
+	output := t.TempDir()
+
+	opts := testOptions()
+	opts.media = assets.ModeCopy
+	if err := run(input, output, opts); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	group := readOutput(t, filepath.Join(output, "Forum", "Forum_january_2024.md"))
+	if !strings.Contains(group, "![](assets/photos/p0.jpg)") {
+		t.Errorf("group file does not link assets/photos/p0.jpg:\n%s", group)
+	}
+	topic := readOutput(t, filepath.Join(output, "Forum", "Dev", "Dev_january_2024.md"))
+	if !strings.Contains(topic, "![](../assets/photos/p1.jpg)") {
+		t.Errorf("topic file does not link ../assets/photos/p1.jpg:\n%s", topic)
+	}
+	if _, err := os.Stat(filepath.Join(output, "Forum", "assets", "photos", "p1.jpg")); err != nil {
+		t.Errorf("topic photo not copied: %v", err)
+	}
+}
+
+func TestRun_NestedPatternLinks(t *testing.T) {
+	input := writeExport(t, `{"name": "Chat", "type": "private_group", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2024-12-30T10:00:00", "from": "Иван", "photo": "photos/p0.jpg", "text": "фото"},
+		{"id": 2, "type": "message", "date": "2025-01-02T10:00:00", "from": "Мария", "reply_to_message_id": 1, "text": "ответ"}
+	]}`, "photos/p0.jpg")
+	output := t.TempDir()
+
+	opts := testOptions()
+	opts.media = assets.ModeCopy
+	opts.threads = true
+	opts.anchors = true
+	opts.split.Pattern = "{yyyy}/{mm}.md"
+	if err := run(input, output, opts); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	dir := filepath.Join(output, "Chat")
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"2024/12.md", "![](../assets/photos/p0.jpg)"},
+		{"2025/01.md", "(../2024/12.md#msg-1)"},
+		{"Chat_threads.md", "![](assets/photos/p0.jpg)"},
+		{"Chat_threads.md", "(2024/12.md#msg-1)"},
+	}
+	for _, tt := range tests {
+		if content := readOutput(t, filepath.Join(dir, filepath.FromSlash(tt.file))); !strings.Contains(content, tt.want) {
+			t.Errorf("%s does not contain %q:\n%s", tt.file, tt.want, content)
+		}
+	}
+}
+
+func TestRun_IncrementalReferences(t *testing.T) {
+	output := t.TempDir()
+	opts := testOptions()
+	opts.links = converter.LinksReference
+	opts.incremental = true
+
+	first := writeExport(t, `{"name": "Chat", "type": "private_group", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2024-01-15T10:00:00", "from": "Иван", "text": [{"type": "text_link", "text": "один", "href": "https://one.example"}]}
+	]}`)
+	if err := run(first, output, opts); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// The newer export only holds messages after the first run's
+	second := writeExport(t, `{"name": "Chat", "type": "private_group", "id": 7, "messages": [
+		{"id": 2, "type": "message", "date": "2024-01-16T10:00:00", "from": "Мария", "text": [{"type": "text_link", "text": "четыре", "href": "https://four.example"}]},
+		{"id": 3, "type": "message", "date": "2024-01-17T10:00:00", "from": "Иван", "text": [{"type": "text_link", "text": "снова", "href": "https://one.example"}]}
+	]}`)
+	if err := run(second, output, opts); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	content := readOutput(t, filepath.Join(output, "Chat", "Chat_january_2024.md"))
+	for _, want := range []string{
+		"[один][1]", "[четыре][2]", "[снова][1]",
+		"[1]: https://one.example", "[2]: https://four.example",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("output does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestRun_LocalizedNames(t *testing.T) {
+	input := writeExport(t, `{"name": "Chat", "type": "private_group", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2024-01-15T10:00:00", "from": "Иван", "text": "привет"}
+	]}`)
+
+	for _, tt := range []struct {
+		localized bool
+		file      string
+	}{
+		{false, "Chat_january_2024.md"},
+		{true, "Chat_январь_2024.md"},
+	} {
+		output := t.TempDir()
+		opts := testOptions()
+		opts.localizedNames = tt.localized
+		if err := run(input, output, opts); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(output, "Chat", tt.file)); err != nil {
+			t.Errorf("localized names %v: %s not written: %v", tt.localized, tt.file, err)
+		}
+	}
+}
+
+func TestRun_ThreadReferences(t *testing.T) {
+	input := writeExport(t, `{"name": "Chat", "type": "private_group", "id": 7, "messages": [
+		{"id": 1, "type": "message", "date": "2024-01-15T10:00:00", "from": "Иван", "text": [{"type": "text_link", "text": "доки", "href": "https://go.dev/doc"}]},
+		{"id": 2, "type": "message", "date": "2024-01-15T10:01:00", "from": "Мария", "reply_to_message_id": 1, "text": "спасибо"}
+	]}`)
+	output := t.TempDir()
+
+	opts := testOptions()
+	opts.threads = true
+	opts.links = converter.LinksReference
+	if err := run(input, output, opts); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	content := readOutput(t, filepath.Join(output, "Chat", "Chat_threads.md"))
+	for _, want := range []string{"[доки][1]", "[1]: https://go.dev/doc"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("threads file does not contain %q:\n%s", want, content)
+		}
+	}
+}
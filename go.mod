@@ -2,7 +2,6 @@ module github.com/grigoriizhovtun/tg2md
 
 go 1.25.5
 
-require (
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
-)
+require golang.org/x/term v0.39.0
+
+require golang.org/x/sys v0.40.0 // indirect
@@ -0,0 +1,159 @@
+package assets
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// Dir is the name of the media directory inside the group directory.
+const Dir = "assets"
+
+// notFound replaces paths whose files are absent from the export folder.
+const notFound = "(File not found in export folder)"
+
+// Mode selects how referenced media files are placed into the output.
+type Mode string
+
+const (
+	// ModeNone leaves media where it is and keeps text labels.
+	ModeNone Mode = "none"
+	// ModeCopy copies every referenced file.
+	ModeCopy Mode = "copy"
+	// ModeLink hard-links files, falling back to a copy across devices.
+	ModeLink Mode = "link"
+)
+
+// ParseMode validates a mode name from the command line.
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeNone, ModeCopy, ModeLink:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown media mode %q (want none, copy or link)", name)
+}
+
+// Importer places media referenced by messages into the assets directory
+// of a group.
+type Importer struct {
+	exportDir string
+	groupDir  string
+	mode      Mode
+	placed    map[string]bool
+}
+
+// New creates an Importer that reads files relative to exportDir and
+// writes them under groupDir/assets.
+func New(exportDir, groupDir string, mode Mode) *Importer {
+	return &Importer{
+		exportDir: exportDir,
+		groupDir:  groupDir,
+		mode:      mode,
+		placed:    make(map[string]bool),
+	}
+}
+
+// Import places the message's photo and file into the assets directory and
+// rewrites their paths to be relative to the group directory.
+// Files that are not part of the export are replaced with a placeholder
+// and reported as errors, so no broken links are produced.
+func (im *Importer) Import(msg *parser.Message) []error {
+	if im.mode == ModeNone {
+		return nil
+	}
+
+	var errs []error
+	for _, field := range []*string{&msg.Photo, &msg.File} {
+		if *field == "" {
+			continue
+		}
+		if parser.IsMissingFile(*field) {
+			errs = append(errs, fmt.Errorf("media not included in export: %s", *field))
+			continue
+		}
+
+		rel, err := im.place(*field)
+		if err != nil {
+			errs = append(errs, err)
+			*field = notFound
+			continue
+		}
+		*field = rel
+	}
+
+	return errs
+}
+
+// place copies or links a single export-relative file and returns its
+// path relative to the group directory.
+func (im *Importer) place(exportPath string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(exportPath))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("media path outside export: %s", exportPath)
+	}
+
+	rel := path.Join(Dir, clean)
+	if im.placed[rel] {
+		return rel, nil
+	}
+
+	src := filepath.Join(im.exportDir, filepath.FromSlash(clean))
+	dst := filepath.Join(im.groupDir, filepath.FromSlash(rel))
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("media file missing: %s", exportPath)
+	}
+
+	// Skip files already placed by a previous run
+	if dstInfo, err := os.Stat(dst); err == nil && dstInfo.Size() == srcInfo.Size() {
+		im.placed[rel] = true
+		return rel, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", fmt.Errorf("create assets directory: %w", err)
+	}
+
+	if im.mode == ModeLink {
+		os.Remove(dst)
+		if err := os.Link(src, dst); err == nil {
+			im.placed[rel] = true
+			return rel, nil
+		}
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return "", err
+	}
+	im.placed[rel] = true
+	return rel, nil
+}
+
+// copyFile copies src to dst, replacing dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open media: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create media copy: %w", err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy media: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close media copy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func setupExport(t *testing.T) (exportDir, groupDir string) {
+	t.Helper()
+
+	exportDir = t.TempDir()
+	groupDir = t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(exportDir, "photos"), 0755); err != nil {
+		t.Fatalf("Failed to create export: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, "photos", "photo_1.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatalf("Failed to write photo: %v", err)
+	}
+	return exportDir, groupDir
+}
+
+func TestImport_CopiesFile(t *testing.T) {
+	exportDir, groupDir := setupExport(t)
+	im := New(exportDir, groupDir, ModeCopy)
+
+	msg := &parser.Message{ID: 1, Photo: "photos/photo_1.jpg"}
+	if errs := im.Import(msg); len(errs) != 0 {
+		t.Fatalf("Import errors: %v", errs)
+	}
+
+	if msg.Photo != "assets/photos/photo_1.jpg" {
+		t.Errorf("Photo = %q, want %q", msg.Photo, "assets/photos/photo_1.jpg")
+	}
+
+	content, err := os.ReadFile(filepath.Join(groupDir, "assets", "photos", "photo_1.jpg"))
+	if err != nil {
+		t.Fatalf("Copied file missing: %v", err)
+	}
+	if string(content) != "jpeg" {
+		t.Errorf("Copied content = %q, want %q", content, "jpeg")
+	}
+}
+
+func TestImport_HardLinksFile(t *testing.T) {
+	exportDir, groupDir := setupExport(t)
+	im := New(exportDir, groupDir, ModeLink)
+
+	msg := &parser.Message{ID: 1, Photo: "photos/photo_1.jpg"}
+	if errs := im.Import(msg); len(errs) != 0 {
+		t.Fatalf("Import errors: %v", errs)
+	}
+
+	if _, err := os.Stat(filepath.Join(groupDir, "assets", "photos", "photo_1.jpg")); err != nil {
+		t.Errorf("Linked file missing: %v", err)
+	}
+}
+
+func TestImport_MissingFiles(t *testing.T) {
+	exportDir, groupDir := setupExport(t)
+	im := New(exportDir, groupDir, ModeCopy)
+
+	msg := &parser.Message{
+		ID:    1,
+		Photo: "photos/deleted.jpg",
+		File:  "(File not included. Change data exporting settings to download.)",
+	}
+	errs := im.Import(msg)
+
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+	if !parser.IsMissingFile(msg.Photo) {
+		t.Errorf("Photo = %q, want a missing-file placeholder", msg.Photo)
+	}
+	if !parser.IsMissingFile(msg.File) {
+		t.Errorf("File = %q, want a missing-file placeholder", msg.File)
+	}
+}
+
+func TestImport_RejectsPathsOutsideExport(t *testing.T) {
+	exportDir, groupDir := setupExport(t)
+	im := New(exportDir, groupDir, ModeCopy)
+
+	msg := &parser.Message{ID: 1, File: "../secret.txt"}
+	errs := im.Import(msg)
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "outside export") {
+		t.Errorf("errors = %v, want outside export error", errs)
+	}
+}
+
+func TestImport_NoneModeLeavesMessage(t *testing.T) {
+	exportDir, groupDir := setupExport(t)
+	im := New(exportDir, groupDir, ModeNone)
+
+	msg := &parser.Message{ID: 1, Photo: "photos/photo_1.jpg"}
+	if errs := im.Import(msg); len(errs) != 0 {
+		t.Fatalf("Import errors: %v", errs)
+	}
+
+	if msg.Photo != "photos/photo_1.jpg" {
+		t.Errorf("Photo = %q, want unchanged", msg.Photo)
+	}
+	if _, err := os.Stat(filepath.Join(groupDir, "assets")); !os.IsNotExist(err) {
+		t.Error("assets directory should not be created")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, name := range []string{"none", "copy", "link"} {
+		if _, err := ParseMode(name); err != nil {
+			t.Errorf("ParseMode(%q) error: %v", name, err)
+		}
+	}
+	if _, err := ParseMode("move"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// AnchorName returns the stable anchor of a message, e.g. "msg-123".
+func AnchorName(id int64) string {
+	return fmt.Sprintf("msg-%d", id)
+}
+
+// anchor returns the HTML anchor of a message, empty when anchors are off.
+func (c *Converter) anchor(id int64) string {
+	if !c.opts.Anchors {
+		return ""
+	}
+	return fmt.Sprintf(`<a id="%s"></a>`, AnchorName(id))
+}
+
+// replyView describes the original of a reply, linked relative to dir
+// when its location is known.
+func (c *Converter) replyView(msg *parser.Message, sent time.Time, dir string) *ReplyView {
+	target := *msg.ReplyToMsgID
+	view := &ReplyView{ID: target, Preview: "..."}
+
+	if cached, ok := c.GetCachedMessage(target); ok && cached != "" {
+		view.Preview = escapeInline(cached)
+	}
+	if c.opts.ReplyLink != nil {
+		if href, ok := c.opts.ReplyLink(msg.ID, target, sent, dir); ok {
+			view.Link = linkDestination(href)
+		}
+	}
+	return view
+}
+
+// pinnedRef renders the number of a message pinned by message from,
+// linked relative to dir when its location is known.
+func (c *Converter) pinnedRef(from int64, sent time.Time, dir string) func(id int64) string {
+	return func(id int64) string {
+		ref := fmt.Sprintf("#%d", id)
+		if c.opts.ReplyLink != nil {
+			if href, ok := c.opts.ReplyLink(from, id, sent, dir); ok {
+				return fmt.Sprintf("[%s](%s)", ref, linkDestination(href))
+			}
+		}
+		return ref
+	}
+}
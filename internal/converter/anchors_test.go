@@ -0,0 +1,130 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestConvertMessage_Anchors(t *testing.T) {
+	links := map[int64]string{1: "../chat_january_2024.md#msg-1"}
+	c := NewWithOptions(Options{
+		Anchors: true,
+		ReplyLink: func(from, to int64, _ time.Time, _ string) (string, bool) {
+			href, ok := links[to]
+			return href, ok
+		},
+	})
+
+	original := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-31T23:59:00",
+		From: "Иван",
+		Text: parser.TextContent{Plain: "Привет"},
+	}
+	result, _, err := c.ConvertMessage(original)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+	expected := `<a id="msg-1"></a>[2024-01-31 23:59] Иван: Привет`
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+
+	for _, tt := range []struct {
+		target int64
+		want   string
+	}{
+		{1, `<a id="msg-2"></a>[2024-02-01 00:01] Мария: [В ответ на: "Привет"](../chat_january_2024.md#msg-1) И тебе`},
+		{7, `<a id="msg-2"></a>[2024-02-01 00:01] Мария: [В ответ на: "..."] И тебе`},
+	} {
+		target := tt.target
+		reply := &parser.Message{
+			ID:           2,
+			Type:         "message",
+			Date:         "2024-02-01T00:01:00",
+			From:         "Мария",
+			ReplyToMsgID: &target,
+			Text:         parser.TextContent{Plain: "И тебе"},
+		}
+		result, _, err := c.ConvertMessage(reply)
+		if err != nil {
+			t.Fatalf("ConvertMessage failed: %v", err)
+		}
+		if result != tt.want {
+			t.Errorf("ConvertMessage() = %q, want %q", result, tt.want)
+		}
+	}
+}
+
+func TestConvertMessage_ServiceAnchor(t *testing.T) {
+	c := NewWithOptions(Options{Anchors: true})
+
+	msg := &parser.Message{
+		ID:     5,
+		Type:   "service",
+		Date:   "2024-01-15T14:30:00",
+		Actor:  "Иван",
+		Action: "pin_message",
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := `<a id="msg-5"></a>[2024-01-15 14:30] [Служебное: Иван закрепил(а) сообщение]`
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertMessage_Relink(t *testing.T) {
+	// Links are relative to the message's directory 2024 unless a
+	// directory is given
+	base := func(dir string) string {
+		if dir == "" {
+			return ""
+		}
+		return "../"
+	}
+	c := NewWithOptions(Options{
+		EmbedMedia: true,
+		ReplyLink: func(from, to int64, _ time.Time, dir string) (string, bool) {
+			return base(dir) + "12.md#msg-1", true
+		},
+		MediaLink: func(from int64, _ time.Time, dir, p string) string {
+			return base(dir) + p
+		},
+	})
+
+	target := int64(1)
+	msg := &parser.Message{
+		ID:           2,
+		Type:         "message",
+		Date:         "2024-12-30T10:00:00",
+		From:         "Мария",
+		ReplyToMsgID: &target,
+		Photo:        "assets/p.jpg",
+		Text:         parser.TextContent{Plain: "ответ"},
+	}
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+	want := `[2024-12-30 10:00] Мария: [В ответ на: "..."](12.md#msg-1) ![](assets/p.jpg) ответ`
+	if result != want {
+		t.Errorf("ConvertMessage() = %q, want %q", result, want)
+	}
+
+	result, err = c.Relink("/out")
+	if err != nil {
+		t.Fatalf("Relink failed: %v", err)
+	}
+	want = `[2024-12-30 10:00] Мария: [В ответ на: "..."](../12.md#msg-1) ![](../assets/p.jpg) ответ`
+	if result != want {
+		t.Errorf("Relink() = %q, want %q", result, want)
+	}
+}
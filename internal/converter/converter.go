@@ -5,137 +5,491 @@ import (
 	"strings"
 	"time"
 
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
 	"github.com/grigoriizhovtun/tg2md/internal/parser"
 	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
 )
 
+// Options configures Markdown rendering.
+type Options struct {
+	// EmbedMedia renders attachments as Markdown images and links to the
+	// imported files instead of text labels.
+	EmbedMedia bool
+
+	// MediaLink returns the link to an imported attachment from message
+	// from, sent at the given time, relative to dir. An empty dir is that
+	// of the file the message is written to, see Relink. path is relative
+	// to the group directory, which is also the default link.
+	MediaLink func(from int64, sent time.Time, dir, path string) string
+
+	// ShowEdits appends an edit marker such as "(изм. YYYY-MM-DD HH:MM)".
+	ShowEdits bool
+
+	// Reactions selects how reactions are rendered after the text.
+	Reactions ReactionMode
+
+	// Lines selects how line breaks inside a message are rendered.
+	Lines LineMode
+
+	// Links selects how links, emails, phones and mentions are rendered.
+	// The zero value keeps only URLs.
+	Links LinkMode
+
+	// References seeds the labels of reference-style links by URL, e.g.
+	// with ReferenceLabels of an earlier run over the chat. New URLs are
+	// numbered after them.
+	References map[string]string
+
+	// Replies stores reply previews. Defaults to an unbounded in-memory map.
+	Replies ReplyCache
+
+	// Anchors prefixes every message with an HTML anchor named by
+	// AnchorName, so replies can link to it.
+	Anchors bool
+
+	// ReplyLink returns the link from message from, sent at the given
+	// time, to the message it replies to relative to dir as in MediaLink,
+	// e.g. "chat_january_2024.md#msg-123". ok is false when the target
+	// has not been written.
+	ReplyLink func(from, to int64, sent time.Time, dir string) (href string, ok bool)
+
+	// Templates renders messages. Defaults to DefaultTemplates in Locale.
+	Templates *Templates
+
+	// Locale supplies labels for media, service messages and the default
+	// template. Defaults to Russian.
+	Locale *locale.Locale
+}
+
+// ReplyCache stores reply previews by message ID.
+type ReplyCache interface {
+	Put(id int64, preview string)
+	Get(id int64) (string, bool)
+}
+
+// replyPreviewLen is the length of quoted text in reply prefixes, in runes.
+const replyPreviewLen = 50
+
+// memoryCache is the default ReplyCache.
+type memoryCache map[int64]string
+
+func (m memoryCache) Put(id int64, preview string) {
+	m[id] = preview
+}
+
+func (m memoryCache) Get(id int64) (string, bool) {
+	preview, ok := m[id]
+	return preview, ok
+}
+
 // Converter transforms parsed messages to Markdown format.
 type Converter struct {
-	messageCache map[int64]string
+	replies ReplyCache
+	topics  map[int64]string
+	topicOf map[int64]int64
+	opts    Options
+
+	templates *Templates
+	locale    *locale.Locale
+
+	// references numbers URLs for reference-style links, lastLabel is the
+	// highest number given, pending holds those used by the last
+	// converted message
+	references map[string]string
+	lastLabel  int
+	pending    []Reference
+
+	// lastForward identifies the forward batch of the previous message
+	lastForward string
+
+	// lastMsg and lastView are the last converted message, kept for Relink
+	lastMsg  *parser.Message
+	lastView MessageView
+
+	unknownEntities map[string]int
 }
 
-// New creates a new Converter.
+// New creates a new Converter with default options.
 func New() *Converter {
-	return &Converter{
-		messageCache: make(map[int64]string),
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates a new Converter with the given options.
+func NewWithOptions(opts Options) *Converter {
+	replies := opts.Replies
+	if replies == nil {
+		replies = make(memoryCache)
+	}
+	loc := opts.Locale
+	if loc == nil {
+		loc = locale.Default()
+	}
+	templates := opts.Templates
+	if templates == nil {
+		templates = DefaultTemplates(loc)
+	}
+	c := &Converter{
+		replies:   replies,
+		templates: templates,
+		locale:    loc,
+		topics:    make(map[int64]string),
+		topicOf:   make(map[int64]int64),
+		opts:      opts,
+
+		references: make(map[string]string),
+
+		unknownEntities: make(map[string]int),
 	}
+	c.seedReferences(opts.References)
+	return c
 }
 
 // ConvertTextEntities converts text entities to Markdown.
+// Entity types without a known mapping are kept as plain text and
+// counted, see UnknownEntities.
 func (c *Converter) ConvertTextEntities(entities []parser.TextEntity) string {
 	var builder strings.Builder
 
-	for _, entity := range entities {
+	for i, entity := range entities {
 		text := sanitizer.SanitizeText(entity.Text)
+		lineStart := strings.HasSuffix(builder.String(), "\n")
 
 		switch entity.Type {
 		case "bold":
-			builder.WriteString("**")
-			builder.WriteString(text)
-			builder.WriteString("**")
+			writeWrapped(&builder, "**", "**", escapeText(text, false))
 		case "italic":
-			builder.WriteString("_")
-			builder.WriteString(text)
-			builder.WriteString("_")
-		case "code", "pre":
-			builder.WriteString("`")
-			builder.WriteString(text)
-			builder.WriteString("`")
+			writeWrapped(&builder, "_", "_", escapeText(text, false))
+		case "underline":
+			writeWrapped(&builder, "<u>", "</u>", escapeText(text, false))
+		case "strikethrough":
+			writeWrapped(&builder, "~~", "~~", escapeText(text, false))
+		case "spoiler":
+			writeWrapped(&builder, "||", "||", escapeText(text, false))
+		case "code":
+			builder.WriteString(codeSpan(text))
+		case "pre":
+			writeCodeBlock(&builder, text, entity.Language, i == len(entities)-1)
+		case "blockquote", "expandable_blockquote":
+			writeBlockquote(&builder, escapeText(text, true), i == len(entities)-1)
 		case "text_link":
-			// Use only URL, discard link text per spec
-			if entity.Href != "" {
+			switch {
+			case entity.Href == "":
+				builder.WriteString(escapeText(text, lineStart))
+			case c.opts.Links == LinksURL || c.opts.Links == "":
+				// URL-only mode discards the link text
 				builder.WriteString(entity.Href)
+			default:
+				builder.WriteString(c.formatLink(text, entity.Href, escapeText(text, lineStart)))
+			}
+		case "mention_name":
+			// Users without a username are linked by ID
+			if entity.UserID != 0 {
+				fmt.Fprintf(&builder, "[%s](tg://user?id=%d)", escapeInline(text), entity.UserID)
 			} else {
-				builder.WriteString(text)
+				builder.WriteString(escapeText(text, lineStart))
 			}
 		case "link":
-			// Plain URL, keep as-is
-			builder.WriteString(text)
-		case "mention", "hashtag", "email", "phone", "plain", "":
-			// Keep text as-is
-			builder.WriteString(text)
+			// Addresses are kept verbatim so they stay clickable
+			builder.WriteString(c.formatLink(text, webURL(text), text))
+		case "email":
+			builder.WriteString(c.formatLink(text, "mailto:"+text, text))
+		case "phone":
+			builder.WriteString(c.formatLink(text, phoneURL(text), escapeText(text, lineStart)))
+		case "mention":
+			builder.WriteString(c.formatLink(text, mentionURL(text), escapeText(text, lineStart)))
+		case "custom_emoji", "hashtag", "cashtag", "bot_command", "bank_card", "plain", "":
+			// Keep text, custom_emoji text is its fallback emoji
+			builder.WriteString(escapeText(text, lineStart))
 		default:
-			// Unknown type, keep text
-			builder.WriteString(text)
+			// Unknown type, keep text and count it for the summary
+			c.unknownEntities[entity.Type]++
+			builder.WriteString(escapeText(text, lineStart))
 		}
 	}
 
 	return builder.String()
 }
 
+// UnknownEntities returns how many entities of each unsupported type were
+// kept as plain text during the run.
+func (c *Converter) UnknownEntities() map[string]int {
+	return c.unknownEntities
+}
+
+// writeWrapped wraps text in inline markers. Surrounding whitespace is kept
+// outside the markers, since "** text**" is not emphasis in Markdown.
+func writeWrapped(builder *strings.Builder, open, close, text string) {
+	core := strings.TrimSpace(text)
+	if core == "" {
+		builder.WriteString(text)
+		return
+	}
+
+	start := strings.Index(text, core)
+	builder.WriteString(text[:start])
+	builder.WriteString(open)
+	builder.WriteString(core)
+	builder.WriteString(close)
+	builder.WriteString(text[start+len(core):])
+}
+
+// writeBlockquote writes text as a blockquote on its own lines.
+// A blank line after the quote keeps following text out of it.
+func writeBlockquote(builder *strings.Builder, text string, last bool) {
+	text = strings.Trim(text, "\n")
+	if text == "" {
+		return
+	}
+
+	current := builder.String()
+	if !strings.HasSuffix(current, "\n") {
+		builder.WriteString("\n")
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(">")
+		if line != "" {
+			builder.WriteString(" ")
+			builder.WriteString(line)
+		}
+	}
+
+	if !last {
+		builder.WriteString("\n\n")
+	}
+}
+
+// writeCodeBlock writes text as a fenced code block on its own lines,
+// tagged with the language when the export provides one.
+func writeCodeBlock(builder *strings.Builder, text, language string, last bool) {
+	text = strings.Trim(text, "\n")
+	if text == "" {
+		return
+	}
+
+	if !strings.HasSuffix(builder.String(), "\n") {
+		builder.WriteString("\n")
+	}
+
+	// Backticks and spaces are not allowed in the info string
+	language = strings.TrimSpace(language)
+	if strings.ContainsAny(language, "` \t\n") {
+		language = ""
+	}
+
+	fence := codeFence(text)
+	builder.WriteString(fence)
+	builder.WriteString(language)
+	builder.WriteString("\n")
+	builder.WriteString(text)
+	builder.WriteString("\n")
+	builder.WriteString(fence)
+
+	if !last {
+		builder.WriteString("\n")
+	}
+}
+
 // ConvertMessage converts a parsed message to Markdown string.
 // Returns the formatted line and any error.
 func (c *Converter) ConvertMessage(msg *parser.Message) (string, time.Time, error) {
+	c.pending = nil
+	c.lastMsg = nil
+
 	// Parse timestamp
 	timestamp, parsedTime, err := formatTimestamp(msg.Date)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("invalid date format: %w", err)
 	}
 
+	// Replies to a topic root only mark topic membership
+	topicReply := c.trackTopic(msg)
+
+	view := MessageView{
+		ID:        msg.ID,
+		Time:      parsedTime,
+		Timestamp: timestamp,
+		Anchor:    c.anchor(msg.ID),
+		AuthorID:  msg.FromID,
+	}
+
 	// Handle service messages
 	if msg.Type == "service" || msg.Action != "" {
+		c.CacheMessage(msg.ID, Preview(msg, c.locale))
+		c.lastForward = ""
+
+		view.Service = true
 		actor := msg.Actor
 		if actor == "" {
 			actor = msg.From
 		}
-		if actor == "" {
-			actor = "Unknown"
+		if actor == "" && msg.Action == "" && msg.Text.Plain != "" {
+			// HTML exports describe service events as ready-made text
+			view.Text = escapeInline(sanitizer.SanitizeText(msg.Text.Plain))
+		} else {
+			view.Actor = userName(actor, c.locale, escapeInline)
+			view.Action = msg.Action
+			view.Text = describeAction(msg, c.locale, escapeInline, c.pinnedRef(msg.ID, parsedTime, ""))
 		}
-		return fmt.Sprintf("[%s] [Служебное: %s %s]", timestamp, actor, msg.Action), parsedTime, nil
+		c.lastMsg, c.lastView = msg, view
+		line, err := c.render(view)
+		return line, parsedTime, err
 	}
 
+	view.Forward = forwardView(msg)
+	if msg.ReplyToMsgID != nil && !topicReply {
+		view.Reply = c.replyView(msg, parsedTime, "")
+	}
+	view.Continued = c.continuesForwards(msg, &view)
+
 	// Convert text content
 	var text string
 	if msg.Text.Plain != "" {
-		text = sanitizer.SanitizeText(msg.Text.Plain)
+		text = escapeText(sanitizer.SanitizeText(msg.Text.Plain), false)
 	} else if len(msg.Text.Entities) > 0 {
 		text = c.ConvertTextEntities(msg.Text.Entities)
 	} else if len(msg.TextEntities) > 0 {
 		text = c.ConvertTextEntities(msg.TextEntities)
 	}
 
+	// Media label goes first, the text becomes its caption
+	media := c.media(msg, parsedTime, "")
+	if media != "" && sanitizer.ContainsOnlyWhitespace(text) {
+		text = ""
+	}
+
 	// Check for empty message
-	if sanitizer.ContainsOnlyWhitespace(text) {
+	if media == "" && sanitizer.ContainsOnlyWhitespace(text) {
 		return "", time.Time{}, fmt.Errorf("empty message")
 	}
+	view.Text = text
+	view.Media = media
+
+	// Cache plain text for reply lookups, it is escaped when quoted
+	c.CacheMessage(msg.ID, Preview(msg, c.locale))
+
+	view.Author = escapeInline(sanitizer.SanitizeText(msg.From))
+	if view.Author == "" {
+		view.Author = c.locale.T("unknown")
+	}
+
+	if c.opts.ShowEdits && msg.Edited != "" {
+		if edited, _, err := formatTimestamp(msg.Edited); err == nil {
+			view.Edited = edited
+		}
+	}
+	view.Reactions = formatReactions(msg.Reactions, c.opts.Reactions, c.locale)
 
-	// Cache for reply lookups
-	c.CacheMessage(msg.ID, text)
+	c.lastMsg, c.lastView = msg, view
+	line, err := c.render(view)
+	return line, parsedTime, err
+}
 
-	// Build message prefix
-	author := msg.From
-	if author == "" {
-		author = "Unknown"
+// Relink renders the last converted message again with its reply, pin
+// and media links relative to dir, e.g. for a copy of the message in a
+// file of another directory.
+func (c *Converter) Relink(dir string) (string, error) {
+	msg, view := c.lastMsg, c.lastView
+	if msg == nil {
+		return "", fmt.Errorf("relink: no converted message")
+	}
+	switch {
+	case view.Service && msg.Action != "":
+		view.Text = describeAction(msg, c.locale, escapeInline, c.pinnedRef(msg.ID, view.Time, dir))
+	case !view.Service:
+		view.Media = c.media(msg, view.Time, dir)
+		if view.Reply != nil {
+			view.Reply = c.replyView(msg, view.Time, dir)
+		}
 	}
+	return c.render(view)
+}
 
-	var prefix string
+// media renders the attachment of a message sent at the given time: a
+// label, or with EmbedMedia a link to the imported file relative to dir.
+func (c *Converter) media(msg *parser.Message, sent time.Time, dir string) string {
+	media := formatMedia(msg, c.locale, escapeInline)
+	if c.opts.EmbedMedia {
+		link := func(p string) string { return p }
+		if c.opts.MediaLink != nil {
+			link = func(p string) string { return c.opts.MediaLink(msg.ID, sent, dir, p) }
+		}
+		if embedded := embedMedia(msg, c.locale, link); embedded != "" {
+			media = embedded
+		}
+	}
+	return media
+}
 
-	// Handle forwarded messages
-	if msg.ForwardedFrom != "" {
-		prefix = fmt.Sprintf("[Переслано от: %s] ", msg.ForwardedFrom)
+// render executes the message template and applies the line mode.
+func (c *Converter) render(view MessageView) (string, error) {
+	line, err := execute(c.templates.message, view)
+	if err != nil {
+		return "", err
 	}
+	return formatLines(line, c.opts.Lines), nil
+}
+
+// Preview returns the unformatted, truncated text of a message as quoted
+// in replies: the text with its media label, or a service event description.
+// Labels are taken from loc.
+func Preview(msg *parser.Message, loc *locale.Locale) string {
+	return truncateForReply(fullPreview(msg, loc), replyPreviewLen)
+}
 
-	// Handle replies (takes precedence over forwarded prefix)
-	if msg.ReplyToMsgID != nil {
-		replyText := "..."
-		if cached, ok := c.GetCachedMessage(*msg.ReplyToMsgID); ok {
-			replyText = truncateForReply(cached, 50)
+// fullPreview returns the untruncated reply preview of a message.
+func fullPreview(msg *parser.Message, loc *locale.Locale) string {
+	if msg.Type == "service" || msg.Action != "" {
+		return servicePreview(msg, loc)
+	}
+
+	entities := msg.Text.Entities
+	if len(entities) == 0 {
+		entities = msg.TextEntities
+	}
+
+	var builder strings.Builder
+	builder.WriteString(msg.Text.Plain)
+	if msg.Text.Plain == "" {
+		for _, entity := range entities {
+			builder.WriteString(entity.Text)
 		}
-		prefix = fmt.Sprintf("[В ответ на: \"%s\"] ", replyText)
 	}
+	text := strings.TrimSpace(sanitizer.SanitizeText(builder.String()))
 
-	return fmt.Sprintf("[%s] %s: %s%s", timestamp, author, prefix, text), parsedTime, nil
+	if media := formatMedia(msg, loc, noEscape); media != "" {
+		if text == "" {
+			return media
+		}
+		return media + " " + text
+	}
+	return text
+}
+
+// servicePreview describes a service message for reply previews.
+func servicePreview(msg *parser.Message, loc *locale.Locale) string {
+	actor := msg.Actor
+	if actor == "" {
+		actor = msg.From
+	}
+	if actor == "" && msg.Action == "" {
+		return fmt.Sprintf("[%s: %s]", loc.T("service"), sanitizer.SanitizeText(msg.Text.Plain))
+	}
+	return fmt.Sprintf("[%s: %s]", loc.T("service"), describeAction(msg, loc, noEscape, plainRef))
 }
 
-// CacheMessage stores message text for reply lookups.
+// CacheMessage stores the reply preview of plain message text. Only the
+// truncated preview is kept.
 func (c *Converter) CacheMessage(id int64, text string) {
-	c.messageCache[id] = text
+	c.replies.Put(id, truncateForReply(text, replyPreviewLen))
 }
 
-// GetCachedMessage retrieves cached message text for replies.
+// GetCachedMessage retrieves the cached reply preview of a message.
 func (c *Converter) GetCachedMessage(id int64) (string, bool) {
-	text, ok := c.messageCache[id]
-	return text, ok
+	return c.replies.Get(id)
 }
 
 // formatTimestamp parses ISO timestamp and formats it as [YYYY-MM-DD HH:MM].
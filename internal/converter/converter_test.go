@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
 	"github.com/grigoriizhovtun/tg2md/internal/parser"
 )
 
@@ -56,7 +57,38 @@ func TestConvertTextEntities_Pre(t *testing.T) {
 	}
 
 	result := c.ConvertTextEntities(entities)
-	expected := "`code block`"
+	expected := "\n```\ncode block\n```"
+
+	if result != expected {
+		t.Errorf("ConvertTextEntities() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertTextEntities_PreWithLanguage(t *testing.T) {
+	c := New()
+	entities := []parser.TextEntity{
+		{Type: "plain", Text: "Смотри:\n"},
+		{Type: "pre", Text: "func main() {\n\tfmt.Println(\"*hi*\")\n}", Language: "go"},
+		{Type: "plain", Text: "\nРаботает"},
+	}
+
+	result := c.ConvertTextEntities(entities)
+	expected := "Смотри:\n```go\nfunc main() {\n\tfmt.Println(\"*hi*\")\n}\n```\n\nРаботает"
+
+	if result != expected {
+		t.Errorf("ConvertTextEntities() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertTextEntities_PreWithBackticks(t *testing.T) {
+	c := New()
+	entities := []parser.TextEntity{
+		{Type: "plain", Text: "README"},
+		{Type: "pre", Text: "```bash\nmake\n```", Language: "markdown"},
+	}
+
+	result := c.ConvertTextEntities(entities)
+	expected := "README\n````markdown\n```bash\nmake\n```\n````"
 
 	if result != expected {
 		t.Errorf("ConvertTextEntities() = %q, want %q", result, expected)
@@ -136,6 +168,70 @@ func TestConvertTextEntities_UnknownType(t *testing.T) {
 	}
 }
 
+func TestConvertTextEntities_AllTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		entities []parser.TextEntity
+		want     string
+	}{
+		{"underline", []parser.TextEntity{{Type: "underline", Text: "важно"}}, "<u>важно</u>"},
+		{"strikethrough", []parser.TextEntity{{Type: "strikethrough", Text: "старое"}}, "~~старое~~"},
+		{"spoiler", []parser.TextEntity{{Type: "spoiler", Text: "сюрприз"}}, "||сюрприз||"},
+		{"custom emoji", []parser.TextEntity{{Type: "custom_emoji", Text: "😎", DocumentID: "5368324170671202286"}}, "😎"},
+		{"mention name", []parser.TextEntity{{Type: "mention_name", Text: "Иван", UserID: 123456}}, "[Иван](tg://user?id=123456)"},
+		{"mention name without id", []parser.TextEntity{{Type: "mention_name", Text: "Иван"}}, "Иван"},
+		{"bot command", []parser.TextEntity{{Type: "bot_command", Text: "/start"}}, "/start"},
+		{"cashtag", []parser.TextEntity{{Type: "cashtag", Text: "$USD"}}, "$USD"},
+		{"bank card", []parser.TextEntity{{Type: "bank_card", Text: "4111 1111 1111 1111"}}, "4111 1111 1111 1111"},
+		{
+			"whitespace stays outside markers",
+			[]parser.TextEntity{{Type: "plain", Text: "это"}, {Type: "bold", Text: " важно "}, {Type: "plain", Text: "!"}},
+			"это **важно** !",
+		},
+		{
+			"blockquote on own lines",
+			[]parser.TextEntity{
+				{Type: "plain", Text: "Цитата:"},
+				{Type: "blockquote", Text: "первая строка\nвторая строка"},
+				{Type: "plain", Text: "Согласен"},
+			},
+			"Цитата:\n> первая строка\n> вторая строка\n\nСогласен",
+		},
+		{
+			"expandable blockquote at the end",
+			[]parser.TextEntity{{Type: "expandable_blockquote", Text: "длинная\n\nцитата", Collapsed: true}},
+			"\n> длинная\n>\n> цитата",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			if got := c.ConvertTextEntities(tt.entities); got != tt.want {
+				t.Errorf("ConvertTextEntities() = %q, want %q", got, tt.want)
+			}
+			if len(c.UnknownEntities()) != 0 {
+				t.Errorf("UnknownEntities() = %v, want none", c.UnknownEntities())
+			}
+		})
+	}
+}
+
+func TestConvertTextEntities_CountsUnknownTypes(t *testing.T) {
+	c := New()
+	c.ConvertTextEntities([]parser.TextEntity{
+		{Type: "future_type", Text: "a"},
+		{Type: "plain", Text: "b"},
+		{Type: "future_type", Text: "c"},
+		{Type: "other_type", Text: "d"},
+	})
+
+	unknown := c.UnknownEntities()
+	if unknown["future_type"] != 2 || unknown["other_type"] != 1 || len(unknown) != 2 {
+		t.Errorf("UnknownEntities() = %v", unknown)
+	}
+}
+
 func TestConvertMessage_Regular(t *testing.T) {
 	c := New()
 	msg := &parser.Message{
@@ -278,6 +374,27 @@ func TestConvertMessage_ServiceWithFromField(t *testing.T) {
 	}
 }
 
+func TestConvertMessage_ServiceTextOnly(t *testing.T) {
+	c := New()
+	msg := &parser.Message{
+		ID:   3,
+		Type: "service",
+		Date: "2024-01-15T14:34:00",
+		Text: parser.TextContent{Plain: "Иван invited Мария"},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:34] [Служебное: Иван invited Мария]"
+
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
 func TestConvertMessage_EmptyText_ReturnsError(t *testing.T) {
 	c := New()
 	msg := &parser.Message{
@@ -441,3 +558,209 @@ func TestCacheMessage(t *testing.T) {
 		t.Error("Expected not to find non-existent message")
 	}
 }
+
+func TestConvertMessage_EscapesPlainText(t *testing.T) {
+	c := New()
+
+	msg := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "user_name",
+		Text: parser.TextContent{Plain: "вызови my_func(*args)\n# не заголовок\n1. не список"},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:30] user\\_name: вызови my\\_func(\\*args)\n\\# не заголовок\n1\\. не список"
+
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertTextEntities_EscapesLiteralText(t *testing.T) {
+	c := New()
+
+	entities := []parser.TextEntity{
+		{Type: "plain", Text: "see "},
+		{Type: "bold", Text: "snake_case"},
+		{Type: "plain", Text: " and "},
+		{Type: "code", Text: "a`b"},
+		{Type: "plain", Text: "\n- item "},
+		{Type: "link", Text: "https://example.com/a_b_c"},
+	}
+
+	result := c.ConvertTextEntities(entities)
+	expected := "see **snake\\_case** and ``a`b``\n\\- item https://example.com/a_b_c"
+
+	if result != expected {
+		t.Errorf("ConvertTextEntities() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertMessage_ReplyQuotesPlainText(t *testing.T) {
+	c := New()
+
+	original := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "Иван",
+		Text: parser.TextContent{Entities: []parser.TextEntity{
+			{Type: "bold", Text: "важный"},
+			{Type: "plain", Text: " my_var"},
+		}},
+	}
+	if _, _, err := c.ConvertMessage(original); err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	replyToID := int64(1)
+	reply := &parser.Message{
+		ID:           2,
+		Type:         "message",
+		Date:         "2024-01-15T14:31:00",
+		From:         "Мария",
+		ReplyToMsgID: &replyToID,
+		Text:         parser.TextContent{Plain: "Да"},
+	}
+
+	result, _, err := c.ConvertMessage(reply)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := `[2024-01-15 14:31] Мария: [В ответ на: "важный my\_var"] Да`
+
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "text",
+			msg:  parser.Message{Type: "message", Text: parser.TextContent{Plain: "Привет"}},
+			want: "Привет",
+		},
+		{
+			name: "entities",
+			msg: parser.Message{Type: "message", Text: parser.TextContent{Entities: []parser.TextEntity{
+				{Type: "bold", Text: "snake_case"},
+				{Type: "plain", Text: " ok"},
+			}}},
+			want: "snake_case ok",
+		},
+		{
+			name: "media only",
+			msg:  parser.Message{Type: "message", Photo: "photos/1.jpg"},
+			want: "[Фото]",
+		},
+		{
+			name: "media with caption",
+			msg:  parser.Message{Type: "message", Photo: "photos/1.jpg", Text: parser.TextContent{Plain: "закат"}},
+			want: "[Фото] закат",
+		},
+		{
+			name: "service",
+			msg:  parser.Message{Type: "service", Actor: "Иван", Action: "pin_message"},
+			want: "[Служебное: Иван закрепил(а) сообщение]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Preview(&tt.msg, locale.Default()); got != tt.want {
+				t.Errorf("Preview() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMessage_ReplyToService(t *testing.T) {
+	c := New()
+
+	service := &parser.Message{
+		ID:     1,
+		Type:   "service",
+		Date:   "2024-01-15T14:30:00",
+		Actor:  "Иван",
+		Action: "pin_message",
+	}
+	if _, _, err := c.ConvertMessage(service); err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	replyToID := int64(1)
+	reply := &parser.Message{
+		ID:           2,
+		Type:         "message",
+		Date:         "2024-01-15T14:31:00",
+		From:         "Мария",
+		ReplyToMsgID: &replyToID,
+		Text:         parser.TextContent{Plain: "Зачем?"},
+	}
+
+	result, _, err := c.ConvertMessage(reply)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := `[2024-01-15 14:31] Мария: [В ответ на: "\[Служебное: Иван закрепил(а) сообщение\]"] Зачем?`
+
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertMessage_EnglishLocale(t *testing.T) {
+	en, _ := locale.Get("en")
+	c := NewWithOptions(Options{Locale: en, ShowEdits: true})
+	c.CacheMessage(1, "Hi")
+
+	replyTo := int64(1)
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "reply with photo",
+			msg: parser.Message{ID: 2, Type: "message", Date: "2024-01-15T14:31:00", From: "Ann",
+				ReplyToMsgID: &replyTo, Photo: "photos/1.jpg", Edited: "2024-01-15T14:35:00"},
+			want: `[2024-01-15 14:31] Ann: [In reply to: "Hi"] [Photo] (edited 2024-01-15 14:35)`,
+		},
+		{
+			name: "forward without author",
+			msg: parser.Message{ID: 3, Type: "message", Date: "2024-01-15T14:32:00",
+				ForwardedFrom: "News", Text: parser.TextContent{Plain: "text"}},
+			want: "[2024-01-15 14:32] Unknown: [Forwarded from: News] text",
+		},
+		{
+			name: "service",
+			msg:  parser.Message{ID: 4, Type: "service", Date: "2024-01-15T14:33:00", Actor: "Ann", Action: "pin_message"},
+			want: "[2024-01-15 14:33] [Service: Ann pinned a message]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := c.ConvertMessage(&tt.msg)
+			if err != nil {
+				t.Fatalf("ConvertMessage failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
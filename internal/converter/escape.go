@@ -0,0 +1,102 @@
+package converter
+
+import "strings"
+
+// inlineMetachars start inline Markdown constructs: emphasis, code spans,
+// links, raw HTML and strikethrough.
+const inlineMetachars = "\\`*_[]<~"
+
+// escapeInline escapes characters that would start inline Markdown markup.
+func escapeInline(text string) string {
+	if !strings.ContainsAny(text, inlineMetachars) {
+		return text
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(text) + 8)
+	for _, r := range text {
+		if strings.ContainsRune(inlineMetachars, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// escapeText escapes literal text for Markdown output: inline metacharacters
+// everywhere and block markers (headings, quotes, lists) at line starts.
+// atLineStart tells whether the first line of text starts a Markdown line.
+func escapeText(text string, atLineStart bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = escapeInline(line)
+		if i > 0 || atLineStart {
+			line = escapeLineStart(line)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeLineStart escapes a block marker at the start of a line, after up
+// to three spaces of indentation.
+func escapeLineStart(line string) string {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent > 3 || indent == len(line) {
+		return line
+	}
+	rest := line[indent:]
+
+	switch rest[0] {
+	case '>', '#', '-', '+', '=':
+		return line[:indent] + "\\" + rest
+	}
+
+	// Ordered list markers: up to nine digits followed by "." or ")"
+	digits := 0
+	for digits < len(rest) && digits < 10 && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits <= 9 && digits < len(rest) && (rest[digits] == '.' || rest[digits] == ')') {
+		return line[:indent] + rest[:digits] + "\\" + rest[digits:]
+	}
+
+	return line
+}
+
+// codeSpan wraps text in an inline code span whose fence is longer than
+// any run of backticks inside the text.
+func codeSpan(text string) string {
+	fence := strings.Repeat("`", longestBacktickRun(text)+1)
+	// A space keeps edge backticks from merging with the fence; one space
+	// on each side is stripped again by Markdown renderers
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// codeFence returns a code block fence longer than any run of backticks
+// inside the text, at least three backticks long.
+func codeFence(text string) string {
+	return strings.Repeat("`", max(3, longestBacktickRun(text)+1))
+}
+
+// longestBacktickRun returns the length of the longest run of backticks.
+func longestBacktickRun(text string) int {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// noEscape leaves text unchanged; used for plain-text previews.
+func noEscape(text string) string {
+	return text
+}
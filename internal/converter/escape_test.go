@@ -0,0 +1,68 @@
+package converter
+
+import "testing"
+
+func TestEscapeInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"обычный текст", "обычный текст"},
+		{"snake_case_name", `snake\_case\_name`},
+		{"2*3*4", `2\*3\*4`},
+		{"a `stray backtick", "a \\`stray backtick"},
+		{"[не ссылка](x)", `\[не ссылка\](x)`},
+		{"<b>html</b>", `\<b>html\</b>`},
+		{"~~not struck~~", `\~\~not struck\~\~`},
+		{`C:\path`, `C:\\path`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeInline(tt.input); got != tt.want {
+			t.Errorf("escapeInline(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		input       string
+		atLineStart bool
+		want        string
+	}{
+		{"# не заголовок", false, "# не заголовок"},
+		{"# заголовок", true, `\# заголовок`},
+		{"строка\n> цитата", false, "строка\n\\> цитата"},
+		{"список:\n- один\n+ два", false, "список:\n\\- один\n\\+ два"},
+		{"шаги:\n1. первый\n2) второй", false, "шаги:\n1\\. первый\n2\\) второй"},
+		{"итог\n===", false, "итог\n\\==="},
+		{"текст\n    - код", false, "текст\n    - код"},
+		{"год\n2024 был", false, "год\n2024 был"},
+		{"a\n\nb", true, "a\n\nb"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.input, tt.atLineStart); got != tt.want {
+			t.Errorf("escapeText(%q, %v) = %q, want %q", tt.input, tt.atLineStart, got, tt.want)
+		}
+	}
+}
+
+func TestCodeSpan(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"fmt.Println()", "`fmt.Println()`"},
+		{"a`b", "``a`b``"},
+		{"x ``` y", "````x ``` y````"},
+		{"`quoted`", "`` `quoted` ``"},
+		{"snake_case", "`snake_case`"},
+	}
+
+	for _, tt := range tests {
+		if got := codeSpan(tt.input); got != tt.want {
+			t.Errorf("codeSpan(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
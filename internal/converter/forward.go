@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+)
+
+// forwardView describes the origin of a forwarded or saved message.
+// Returns nil for messages written in the chat.
+func forwardView(msg *parser.Message) *ForwardView {
+	view := &ForwardView{FromID: msg.ForwardedFromID}
+	switch {
+	case msg.ForwardedFrom != "":
+		view.From = escapeInline(sanitizer.SanitizeText(msg.ForwardedFrom))
+	case msg.SavedFrom != "":
+		view.From = escapeInline(sanitizer.SanitizeText(msg.SavedFrom))
+		view.Saved = true
+	default:
+		return nil
+	}
+	if _, t, err := formatTimestamp(msg.ForwardedDate); err == nil {
+		view.Date = t.Format("2006-01-02")
+	}
+	return view
+}
+
+// continuesForwards reports whether a forward continues a batch of
+// forwards from the same source, sent together by the same author. Replies
+// and messages of other topics break the batch.
+func (c *Converter) continuesForwards(msg *parser.Message, view *MessageView) bool {
+	previous := c.lastForward
+	c.lastForward = ""
+	if view.Forward == nil || view.Reply != nil {
+		return false
+	}
+
+	source := view.Forward.FromID
+	if source == "" {
+		source = view.Forward.From
+	}
+	author := msg.FromID
+	if author == "" {
+		author = msg.From
+	}
+	c.lastForward = fmt.Sprintf("%s\x00%s\x00%s\x00%d", author, source, view.Timestamp, c.topicOf[msg.ID])
+	return c.lastForward == previous
+}
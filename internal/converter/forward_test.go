@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestConvertMessage_ForwardMetadata(t *testing.T) {
+	replyTo := int64(1)
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "original date",
+			msg: parser.Message{ID: 2, Type: "message", Date: "2024-01-15T14:32:00", From: "Пётр",
+				ForwardedFrom: "Канал X", ForwardedDate: "2023-11-02T09:00:00", Text: parser.TextContent{Plain: "Новость"}},
+			want: "[2024-01-15 14:32] Пётр: [Переслано от: Канал X, 2023-11-02] Новость",
+		},
+		{
+			name: "forwarded reply",
+			msg: parser.Message{ID: 3, Type: "message", Date: "2024-01-15T14:33:00", From: "Пётр",
+				ForwardedFrom: "Алексей", ReplyToMsgID: &replyTo, Text: parser.TextContent{Plain: "Согласен"}},
+			want: `[2024-01-15 14:33] Пётр: [В ответ на: "Привет"] [Переслано от: Алексей] Согласен`,
+		},
+		{
+			name: "saved from",
+			msg: parser.Message{ID: 4, Type: "message", Date: "2024-01-15T14:34:00", From: "Пётр",
+				SavedFrom: "Рабочий чат", Text: parser.TextContent{Plain: "Заметка"}},
+			want: "[2024-01-15 14:34] Пётр: [Сохранено из: Рабочий чат] Заметка",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.CacheMessage(1, "Привет")
+			got, _, err := c.ConvertMessage(&tt.msg)
+			if err != nil {
+				t.Fatalf("ConvertMessage failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMessage_CollapsesForwardBatch(t *testing.T) {
+	c := New()
+	forward := func(id int64, date, source, text string) *parser.Message {
+		return &parser.Message{ID: id, Type: "message", Date: date, From: "Пётр", FromID: "user1",
+			ForwardedFrom: source, ForwardedFromID: "channel" + source, Text: parser.TextContent{Plain: text}}
+	}
+
+	messages := []*parser.Message{
+		forward(1, "2024-01-15T14:32:00", "X", "первое"),
+		forward(2, "2024-01-15T14:32:00", "X", "второе"),
+		forward(3, "2024-01-15T14:32:00", "Y", "другой источник"),
+		forward(4, "2024-01-15T14:40:00", "Y", "позже"),
+	}
+	expected := []string{
+		"[2024-01-15 14:32] Пётр: [Переслано от: X] первое",
+		"↪ Пётр: второе",
+		"[2024-01-15 14:32] Пётр: [Переслано от: Y] другой источник",
+		"[2024-01-15 14:40] Пётр: [Переслано от: Y] позже",
+	}
+
+	for i, msg := range messages {
+		got, _, err := c.ConvertMessage(msg)
+		if err != nil {
+			t.Fatalf("ConvertMessage failed: %v", err)
+		}
+		if got != expected[i] {
+			t.Errorf("message %d = %q, want %q", msg.ID, got, expected[i])
+		}
+	}
+}
+
+func TestConvertMessage_ContinuedForwardHeader(t *testing.T) {
+	c := New()
+	forward := func(id int64, text parser.TextContent) *parser.Message {
+		return &parser.Message{ID: id, Type: "message", Date: "2024-01-15T14:32:00", From: "Пётр", FromID: "user1",
+			ForwardedFrom: "X", ForwardedFromID: "channelX", Text: text}
+	}
+
+	messages := []*parser.Message{
+		forward(1, parser.TextContent{Plain: "# первое"}),
+		forward(2, parser.TextContent{Plain: "# второе"}),
+		forward(3, parser.TextContent{Entities: []parser.TextEntity{
+			{Type: "plain", Text: "- третье "},
+			{Type: "bold", Text: "жирно"},
+		}}),
+	}
+	expected := []string{
+		"[2024-01-15 14:32] Пётр: [Переслано от: X] # первое",
+		"↪ Пётр: # второе",
+		"↪ Пётр: - третье **жирно**",
+	}
+
+	for i, msg := range messages {
+		got, _, err := c.ConvertMessage(msg)
+		if err != nil {
+			t.Fatalf("ConvertMessage failed: %v", err)
+		}
+		if got != expected[i] {
+			t.Errorf("message %d = %q, want %q", msg.ID, got, expected[i])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineMode selects how line breaks inside a message are rendered.
+type LineMode string
+
+const (
+	// LinesRaw writes message lines as they are; Markdown joins them
+	// into one paragraph.
+	LinesRaw LineMode = "raw"
+	// LinesBreak ends lines with hard breaks and keeps blank lines inside
+	// a message, so the message renders as one block with its own lines.
+	LinesBreak LineMode = "break"
+	// LinesIndent also indents continuation lines under the header line,
+	// so message boundaries are visible in the raw file.
+	LinesIndent LineMode = "indent"
+)
+
+// continuationIndent is short enough to keep quotes, lists and fences
+// recognised and never turns text into an indented code block.
+const continuationIndent = "  "
+
+// ParseLineMode validates a line mode name from the command line.
+func ParseLineMode(name string) (LineMode, error) {
+	switch mode := LineMode(name); mode {
+	case LinesRaw, LinesBreak, LinesIndent:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown lines mode %q (want raw, break or indent)", name)
+}
+
+// lineKind classifies a line of converted Markdown.
+type lineKind int
+
+const (
+	lineText lineKind = iota
+	lineBlank
+	lineQuote
+	lineList
+	lineFence
+	lineCode
+)
+
+// formatLines applies mode to a converted message. Code block contents
+// and the blank lines that end quotes and code blocks are left alone.
+func formatLines(text string, mode LineMode) string {
+	if (mode != LinesBreak && mode != LinesIndent) || !strings.Contains(text, "\n") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	kinds := classifyLines(lines)
+
+	for i := 0; i < len(lines); i++ {
+		if kinds[i] != lineText && kinds[i] != lineList && kinds[i] != lineQuote {
+			continue
+		}
+
+		next := i + 1
+		for next < len(lines) && kinds[next] == lineBlank {
+			next++
+		}
+		if next == len(lines) {
+			break
+		}
+
+		switch {
+		case next > i+1:
+			// Blank lines between text lines become empty hard breaks
+			if kinds[i] != lineText || kinds[next] != lineText {
+				continue
+			}
+			for j := i + 1; j < next; j++ {
+				lines[j] = "\\"
+			}
+		case kinds[i] == lineQuote:
+			// Quote lines break only within the same paragraph
+			if kinds[next] != lineQuote || lines[next] == ">" || lines[i] == ">" {
+				continue
+			}
+		case kinds[next] != lineText:
+			// Lists, quotes and fences start their own blocks
+			continue
+		}
+		lines[i] += "\\"
+	}
+
+	if mode == LinesIndent {
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = continuationIndent + lines[i]
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// classifyLines marks the block structure tg2md produces. Literal text
+// has its block markers escaped, so unescaped markers are our own.
+func classifyLines(lines []string) []lineKind {
+	kinds := make([]lineKind, len(lines))
+	fence := ""
+
+	for i, line := range lines {
+		switch {
+		case fence != "":
+			kinds[i] = lineCode
+			if strings.HasPrefix(line, fence) && strings.Trim(line, "`") == "" {
+				kinds[i] = lineFence
+				fence = ""
+			}
+		case isFenceOpen(line):
+			kinds[i] = lineFence
+			fence = line[:len(line)-len(strings.TrimLeft(line, "`"))]
+		case line == "":
+			kinds[i] = lineBlank
+		case strings.HasPrefix(line, ">"):
+			kinds[i] = lineQuote
+		case strings.HasPrefix(line, "- "):
+			kinds[i] = lineList
+		default:
+			kinds[i] = lineText
+		}
+	}
+
+	return kinds
+}
+
+// isFenceOpen reports whether line opens a backtick code fence.
+func isFenceOpen(line string) bool {
+	info := strings.TrimLeft(line, "`")
+	return len(line)-len(info) >= 3 && !strings.Contains(info, "`")
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestFormatLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		mode  LineMode
+		want  string
+	}{
+		{
+			name:  "single line",
+			input: "[2024-01-15 14:30] Иван: Привет",
+			mode:  LinesBreak,
+			want:  "[2024-01-15 14:30] Иван: Привет",
+		},
+		{
+			name:  "raw",
+			input: "Иван: a\nb",
+			mode:  LinesRaw,
+			want:  "Иван: a\nb",
+		},
+		{
+			name:  "hard breaks",
+			input: "Иван: a\nb\nc",
+			mode:  LinesBreak,
+			want:  "Иван: a\\\nb\\\nc",
+		},
+		{
+			name:  "blank lines inside message",
+			input: "Иван: a\n\n\nb",
+			mode:  LinesBreak,
+			want:  "Иван: a\\\n\\\n\\\nb",
+		},
+		{
+			name:  "quote",
+			input: "Иван: смотри\n> first\n> second\n>\n> third\n\nответ",
+			mode:  LinesBreak,
+			want:  "Иван: смотри\n> first\\\n> second\n>\n> third\n\nответ",
+		},
+		{
+			name:  "code block",
+			input: "Иван: код\n```go\na := 1\n\nb := 2\n```\nok\ndone",
+			mode:  LinesBreak,
+			want:  "Иван: код\n```go\na := 1\n\nb := 2\n```\nok\\\ndone",
+		},
+		{
+			name:  "poll list",
+			input: "Иван: [Опрос: Q]\n- A — 1 голос\n- B — 0 голосов\nВсего: 1 голос",
+			mode:  LinesBreak,
+			want:  "Иван: [Опрос: Q]\n- A — 1 голос\n- B — 0 голосов\\\nВсего: 1 голос",
+		},
+		{
+			name:  "indent",
+			input: "Иван: a\n\nb\n```\nx\n```",
+			mode:  LinesIndent,
+			want:  "Иван: a\\\n  \\\n  b\n  ```\n  x\n  ```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLines(tt.input, tt.mode); got != tt.want {
+				t.Errorf("formatLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineMode(t *testing.T) {
+	for _, name := range []string{"raw", "break", "indent"} {
+		if mode, err := ParseLineMode(name); err != nil || string(mode) != name {
+			t.Errorf("ParseLineMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+	if _, err := ParseLineMode("wrap"); err == nil {
+		t.Error("ParseLineMode(\"wrap\") should fail")
+	}
+}
+
+func TestConvertMessage_MultiLine(t *testing.T) {
+	c := NewWithOptions(Options{Lines: LinesBreak})
+
+	msg := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "Иван",
+		Text: parser.TextContent{Plain: "Первая строка\nвторая\n\nпосле пустой"},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:30] Иван: Первая строка\\\nвторая\\\n\\\nпосле пустой"
+
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
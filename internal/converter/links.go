@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// LinkMode selects how links, emails, phones and mentions are rendered.
+type LinkMode string
+
+const (
+	// LinksInline renders Markdown links: [text](url).
+	LinksInline LinkMode = "inline"
+	// LinksURL keeps only the address, dropping the text of text links.
+	LinksURL LinkMode = "url"
+	// LinksReference renders [text][n] with definitions at the end of
+	// each output file.
+	LinksReference LinkMode = "reference"
+	// LinksText keeps only the visible text.
+	LinksText LinkMode = "text"
+)
+
+// ParseLinkMode validates a link mode name from the command line.
+func ParseLinkMode(name string) (LinkMode, error) {
+	switch mode := LinkMode(name); mode {
+	case LinksInline, LinksURL, LinksReference, LinksText:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown links mode %q (want inline, url, reference or text)", name)
+}
+
+// Reference is a link definition used by a converted message. URL is
+// ready to be written as a link destination.
+type Reference struct {
+	Label string
+	URL   string
+}
+
+// References returns the link definitions used by the last converted
+// message in reference mode. Labels are numbered once per converter, so
+// a URL keeps its label across output files.
+func (c *Converter) References() []Reference {
+	return c.pending
+}
+
+// ReferenceLabels returns the labels given to URLs so far, to seed the
+// converter of a later run through Options.References.
+func (c *Converter) ReferenceLabels() map[string]string {
+	return maps.Clone(c.references)
+}
+
+// seedReferences takes over labels given by an earlier run.
+func (c *Converter) seedReferences(labels map[string]string) {
+	for url, label := range labels {
+		c.references[url] = label
+		if n, err := strconv.Atoi(label); err == nil && n > c.lastLabel {
+			c.lastLabel = n
+		}
+	}
+}
+
+// formatLink renders a link-like entity with visible text and link target
+// url. plain is the rendering without link markup, used by the URL-only and
+// text-only modes.
+func (c *Converter) formatLink(text, url, plain string) string {
+	switch c.opts.Links {
+	case LinksInline:
+		if text == url && isAutolink(url) {
+			return "<" + url + ">"
+		}
+		return fmt.Sprintf("[%s](%s)", escapeInline(text), linkDestination(url))
+	case LinksReference:
+		return fmt.Sprintf("[%s][%s]", escapeInline(text), c.reference(url))
+	}
+	return plain
+}
+
+// reference returns the label for url, assigning the next number to URLs
+// seen for the first time.
+func (c *Converter) reference(url string) string {
+	label, ok := c.references[url]
+	if !ok {
+		c.lastLabel++
+		label = strconv.Itoa(c.lastLabel)
+		c.references[url] = label
+	}
+	c.pending = append(c.pending, Reference{Label: label, URL: linkDestination(url)})
+	return label
+}
+
+// isAutolink reports whether url can be written as an <url> autolink.
+func isAutolink(url string) bool {
+	scheme, _, ok := strings.Cut(url, ":")
+	return ok && len(scheme) >= 2 && !strings.ContainsAny(url, " <>")
+}
+
+// webURL adds a scheme to link entities written without one.
+func webURL(text string) string {
+	if strings.Contains(text, "://") || strings.HasPrefix(text, "mailto:") {
+		return text
+	}
+	return "https://" + text
+}
+
+// phoneURL builds a tel: URL from a phone number as written in the text.
+func phoneURL(text string) string {
+	var builder strings.Builder
+	builder.WriteString("tel:")
+	for i, r := range text {
+		if r >= '0' && r <= '9' || r == '+' && i == 0 {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// mentionURL links a @username mention to its public profile.
+func mentionURL(text string) string {
+	return "https://t.me/" + strings.TrimPrefix(text, "@")
+}
@@ -0,0 +1,162 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestConvertTextEntities_LinkModes(t *testing.T) {
+	entities := []parser.TextEntity{
+		{Type: "plain", Text: "см. "},
+		{Type: "text_link", Text: "the RFC", Href: "https://www.rfc-editor.org/rfc/rfc9110"},
+		{Type: "plain", Text: ", "},
+		{Type: "link", Text: "example.com/a_b"},
+		{Type: "plain", Text: ", "},
+		{Type: "email", Text: "a@b.ru"},
+		{Type: "plain", Text: ", "},
+		{Type: "phone", Text: "+7 (900) 123-45-67"},
+		{Type: "plain", Text: ", "},
+		{Type: "mention", Text: "@user_name"},
+	}
+
+	tests := []struct {
+		mode LinkMode
+		want string
+	}{
+		{
+			mode: LinksInline,
+			want: "см. [the RFC](https://www.rfc-editor.org/rfc/rfc9110), " +
+				"[example.com/a\\_b](https://example.com/a_b), " +
+				"[a@b.ru](mailto:a@b.ru), " +
+				"[+7 (900) 123-45-67](tel:+79001234567), " +
+				"[@user\\_name](https://t.me/user_name)",
+		},
+		{
+			mode: LinksURL,
+			want: "см. https://www.rfc-editor.org/rfc/rfc9110, example.com/a_b, a@b.ru, " +
+				"+7 (900) 123-45-67, @user\\_name",
+		},
+		{
+			mode: LinksReference,
+			want: "см. [the RFC][1], [example.com/a\\_b][2], [a@b.ru][3], " +
+				"[+7 (900) 123-45-67][4], [@user\\_name][5]",
+		},
+		{
+			mode: LinksText,
+			want: "см. the RFC, example.com/a_b, a@b.ru, +7 (900) 123-45-67, @user\\_name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			c := NewWithOptions(Options{Links: tt.mode})
+			if got := c.ConvertTextEntities(entities); got != tt.want {
+				t.Errorf("ConvertTextEntities() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertTextEntities_InlineAutolink(t *testing.T) {
+	c := NewWithOptions(Options{Links: LinksInline})
+	entities := []parser.TextEntity{
+		{Type: "link", Text: "https://example.com"},
+	}
+
+	result := c.ConvertTextEntities(entities)
+	expected := "<https://example.com>"
+
+	if result != expected {
+		t.Errorf("ConvertTextEntities() = %q, want %q", result, expected)
+	}
+}
+
+func TestConverter_References(t *testing.T) {
+	c := NewWithOptions(Options{Links: LinksReference})
+
+	first := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "Иван",
+		Text: parser.TextContent{Entities: []parser.TextEntity{
+			{Type: "text_link", Text: "docs", Href: "https://go.dev/doc"},
+		}},
+	}
+	second := &parser.Message{
+		ID:   2,
+		Type: "message",
+		Date: "2024-01-15T14:31:00",
+		From: "Мария",
+		Text: parser.TextContent{Entities: []parser.TextEntity{
+			{Type: "text_link", Text: "spec", Href: "https://go.dev/ref/spec"},
+			{Type: "plain", Text: " и "},
+			{Type: "text_link", Text: "docs", Href: "https://go.dev/doc"},
+		}},
+	}
+
+	if _, _, err := c.ConvertMessage(first); err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+	result, _, err := c.ConvertMessage(second)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:31] Мария: [spec][2] и [docs][1]"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+
+	want := []Reference{
+		{Label: "2", URL: "https://go.dev/ref/spec"},
+		{Label: "1", URL: "https://go.dev/doc"},
+	}
+	if got := c.References(); !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+}
+
+func TestConverter_SeededReferences(t *testing.T) {
+	c := NewWithOptions(Options{
+		Links:      LinksReference,
+		References: map[string]string{"https://go.dev/doc": "1", "https://go.dev/blog": "2"},
+	})
+
+	msg := &parser.Message{
+		ID:   3,
+		Type: "message",
+		Date: "2024-01-15T14:32:00",
+		From: "Иван",
+		Text: parser.TextContent{Entities: []parser.TextEntity{
+			{Type: "text_link", Text: "spec", Href: "https://go.dev/ref/spec"},
+			{Type: "plain", Text: " и "},
+			{Type: "text_link", Text: "docs", Href: "https://go.dev/doc"},
+		}},
+	}
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:32] Иван: [spec][3] и [docs][1]"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+	if got := c.ReferenceLabels()["https://go.dev/ref/spec"]; got != "3" {
+		t.Errorf("ReferenceLabels()[spec] = %q, want %q", got, "3")
+	}
+}
+
+func TestParseLinkMode(t *testing.T) {
+	for _, name := range []string{"inline", "url", "reference", "text"} {
+		if mode, err := ParseLinkMode(name); err != nil || string(mode) != name {
+			t.Errorf("ParseLinkMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+	if _, err := ParseLinkMode("footnote"); err == nil {
+		t.Error("ParseLinkMode(\"footnote\") should fail")
+	}
+}
@@ -0,0 +1,152 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+)
+
+// formatMedia returns a human-readable label for the message attachment,
+// e.g. "[Фото]" or "[Голосовое 0:42]". Returns an empty string for
+// messages without media. esc escapes user-provided names.
+func formatMedia(msg *parser.Message, loc *locale.Locale, esc func(string) string) string {
+	if structured := formatStructured(msg, loc, esc); structured != "" {
+		return structured
+	}
+
+	switch msg.MediaType {
+	case "sticker":
+		if msg.StickerEmoji != "" {
+			return fmt.Sprintf("[%s %s]", loc.T("sticker"), msg.StickerEmoji)
+		}
+		return "[" + loc.T("sticker") + "]"
+	case "animation":
+		return "[" + loc.T("gif") + "]"
+	case "voice_message":
+		return withDuration(loc.T("voice"), msg.DurationSeconds)
+	case "video_message":
+		return withDuration(loc.T("video_message"), msg.DurationSeconds)
+	case "video_file":
+		return withDuration(loc.T("video"), msg.DurationSeconds)
+	case "audio_file":
+		label := loc.T("audio")
+		if name := fileName(msg, esc); name != "" {
+			label += ": " + name
+		}
+		return withDuration(label, msg.DurationSeconds)
+	}
+
+	if msg.Photo != "" {
+		return "[" + loc.T("photo") + "]"
+	}
+
+	if msg.File != "" || msg.MediaType != "" {
+		details := []string{}
+		if name := fileName(msg, esc); name != "" {
+			details = append(details, name)
+		}
+		if msg.FileSize > 0 {
+			details = append(details, formatSize(msg.FileSize, loc))
+		}
+		if len(details) == 0 {
+			return "[" + loc.T("file") + "]"
+		}
+		return fmt.Sprintf("[%s: %s]", loc.T("file"), strings.Join(details, ", "))
+	}
+
+	return ""
+}
+
+// imageExtensions lists attachment types that Markdown viewers display inline.
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+}
+
+// embedMedia renders an imported attachment as a Markdown image or link,
+// with link turning the imported path into the link destination.
+// Returns an empty string when there is no file to link to.
+func embedMedia(msg *parser.Message, loc *locale.Locale, link func(string) string) string {
+	if isLinkable(msg.Photo) {
+		return fmt.Sprintf("![](%s)", linkDestination(link(msg.Photo)))
+	}
+	if !isLinkable(msg.File) {
+		return ""
+	}
+
+	if msg.MediaType == "sticker" && imageExtensions[strings.ToLower(path.Ext(msg.File))] {
+		return fmt.Sprintf("![](%s)", linkDestination(link(msg.File)))
+	}
+
+	label := fileName(msg, escapeInline)
+	if msg.MediaType != "" {
+		label = strings.TrimSuffix(strings.TrimPrefix(formatMedia(msg, loc, escapeInline), "["), "]")
+	}
+	if label == "" {
+		label = escapeInline(path.Base(msg.File))
+	}
+	return fmt.Sprintf("[%s](%s)", label, linkDestination(link(msg.File)))
+}
+
+// isLinkable reports whether a media path points to a real file.
+func isLinkable(p string) bool {
+	return p != "" && !parser.IsMissingFile(p)
+}
+
+// linkDestination wraps paths with spaces or parentheses in angle brackets
+// so they stay valid Markdown link destinations.
+func linkDestination(p string) string {
+	if strings.ContainsAny(p, " ()<>") {
+		return "<" + p + ">"
+	}
+	return p
+}
+
+// fileName returns the original file name, falling back to the base name
+// of the exported path when the file was included in the export.
+func fileName(msg *parser.Message, esc func(string) string) string {
+	if msg.FileName != "" {
+		return esc(sanitizer.SanitizeText(msg.FileName))
+	}
+	if msg.File != "" && !parser.IsMissingFile(msg.File) {
+		return esc(path.Base(msg.File))
+	}
+	return ""
+}
+
+// withDuration formats a bracketed label with an optional duration.
+func withDuration(label string, seconds int) string {
+	if seconds <= 0 {
+		return fmt.Sprintf("[%s]", label)
+	}
+	return fmt.Sprintf("[%s %s]", label, formatDuration(seconds))
+}
+
+// formatDuration formats seconds as m:ss or h:mm:ss.
+func formatDuration(seconds int) string {
+	h := seconds / 3600
+	m := seconds % 3600 / 60
+	s := seconds % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// formatSize formats a byte count with binary units and localized suffixes.
+func formatSize(size int64, loc *locale.Locale) string {
+	units := []string{loc.T("size.b"), loc.T("size.kb"), loc.T("size.mb"), loc.T("size.gb")}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
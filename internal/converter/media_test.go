@@ -0,0 +1,169 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestFormatMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "photo",
+			msg:  parser.Message{Photo: "photos/photo_1.jpg", Width: 800, Height: 600},
+			want: "[Фото]",
+		},
+		{
+			name: "voice message",
+			msg:  parser.Message{File: "voice_messages/audio_1.ogg", MediaType: "voice_message", DurationSeconds: 42},
+			want: "[Голосовое 0:42]",
+		},
+		{
+			name: "video message",
+			msg:  parser.Message{File: "round_video_messages/file_1.mp4", MediaType: "video_message", DurationSeconds: 9},
+			want: "[Видеосообщение 0:09]",
+		},
+		{
+			name: "long video",
+			msg:  parser.Message{File: "video_files/movie.mp4", MediaType: "video_file", DurationSeconds: 3725},
+			want: "[Видео 1:02:05]",
+		},
+		{
+			name: "audio file",
+			msg:  parser.Message{File: "files/song.mp3", FileName: "song.mp3", MediaType: "audio_file", DurationSeconds: 200},
+			want: "[Аудио: song.mp3 3:20]",
+		},
+		{
+			name: "sticker",
+			msg:  parser.Message{File: "stickers/sticker.webp", MediaType: "sticker", StickerEmoji: "😂"},
+			want: "[Стикер 😂]",
+		},
+		{
+			name: "animation",
+			msg:  parser.Message{File: "video_files/anim.mp4", MediaType: "animation", MimeType: "video/mp4"},
+			want: "[GIF]",
+		},
+		{
+			name: "document",
+			msg:  parser.Message{File: "files/report.pdf", FileName: "report.pdf", FileSize: 1258291, MimeType: "application/pdf"},
+			want: "[Файл: report.pdf, 1.2 МБ]",
+		},
+		{
+			name: "missing document",
+			msg:  parser.Message{File: "(File not included. Change data exporting settings to download.)", FileSize: 512},
+			want: "[Файл: 512 Б]",
+		},
+		{
+			name: "no media",
+			msg:  parser.Message{Text: parser.TextContent{Plain: "текст"}},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMedia(&tt.msg, locale.Default(), escapeInline); got != tt.want {
+				t.Errorf("formatMedia() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMessage_PhotoWithoutCaption(t *testing.T) {
+	c := New()
+	msg := &parser.Message{
+		ID:    1,
+		Type:  "message",
+		Date:  "2024-01-15T14:30:00",
+		From:  "Иван",
+		Photo: "photos/photo_1.jpg",
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:30] Иван: [Фото]"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertMessage_MediaWithCaption(t *testing.T) {
+	c := New()
+	msg := &parser.Message{
+		ID:              1,
+		Type:            "message",
+		Date:            "2024-01-15T14:30:00",
+		From:            "Иван",
+		File:            "voice_messages/audio_1.ogg",
+		MediaType:       "voice_message",
+		DurationSeconds: 42,
+		Text:            parser.TextContent{Plain: "послушай"},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:30] Иван: [Голосовое 0:42] послушай"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestConvertMessage_EmbedMedia(t *testing.T) {
+	c := NewWithOptions(Options{EmbedMedia: true})
+
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "image",
+			msg:  parser.Message{Photo: "assets/photos/photo_1.jpg", Text: parser.TextContent{Plain: "смотри"}},
+			want: "[2024-01-15 14:30] Иван: ![](assets/photos/photo_1.jpg) смотри",
+		},
+		{
+			name: "file with spaces",
+			msg:  parser.Message{File: "assets/files/my report.pdf", FileName: "my report.pdf"},
+			want: "[2024-01-15 14:30] Иван: [my report.pdf](<assets/files/my report.pdf>)",
+		},
+		{
+			name: "voice message",
+			msg:  parser.Message{File: "assets/voice_messages/audio_1.ogg", MediaType: "voice_message", DurationSeconds: 42},
+			want: "[2024-01-15 14:30] Иван: [Голосовое 0:42](assets/voice_messages/audio_1.ogg)",
+		},
+		{
+			name: "missing file keeps label",
+			msg:  parser.Message{Photo: "(File not found in export folder)"},
+			want: "[2024-01-15 14:30] Иван: [Фото]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			msg.ID = 1
+			msg.Type = "message"
+			msg.Date = "2024-01-15T14:30:00"
+			msg.From = "Иван"
+
+			result, _, err := c.ConvertMessage(&msg)
+			if err != nil {
+				t.Fatalf("ConvertMessage failed: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("ConvertMessage() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+)
+
+// ReactionMode selects how message reactions are rendered.
+type ReactionMode string
+
+const (
+	// ReactionsNone drops reactions.
+	ReactionsNone ReactionMode = "none"
+	// ReactionsSummary renders emoji with counts, e.g. "👍3 🔥1".
+	ReactionsSummary ReactionMode = "summary"
+	// ReactionsFull also lists the recent reactors from the export.
+	ReactionsFull ReactionMode = "full"
+)
+
+// ParseReactionMode validates a reaction mode name from the command line.
+func ParseReactionMode(name string) (ReactionMode, error) {
+	switch mode := ReactionMode(name); mode {
+	case ReactionsNone, ReactionsSummary, ReactionsFull:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown reactions mode %q (want none, summary or full)", name)
+}
+
+// formatReactions renders the reactions of a message according to mode.
+// Returns an empty string when there is nothing to show.
+func formatReactions(reactions []parser.Reaction, mode ReactionMode, loc *locale.Locale) string {
+	if mode != ReactionsSummary && mode != ReactionsFull {
+		return ""
+	}
+
+	parts := make([]string, 0, len(reactions))
+	for _, r := range reactions {
+		if r.Count <= 0 {
+			continue
+		}
+
+		part := fmt.Sprintf("%s%d", reactionSymbol(r, loc), r.Count)
+		if mode == ReactionsFull && len(r.Recent) > 0 {
+			names := make([]string, 0, len(r.Recent))
+			for _, user := range r.Recent {
+				name := escapeInline(sanitizer.SanitizeText(user.From))
+				if name == "" {
+					name = loc.T("unknown")
+				}
+				names = append(names, name)
+			}
+			// The export lists only the most recent reactors
+			if r.Count > len(r.Recent) {
+				names = append(names, "…")
+			}
+			part += " (" + strings.Join(names, ", ") + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// reactionSymbol returns the visible symbol for a reaction.
+// Custom emoji have no Unicode form in the export.
+func reactionSymbol(r parser.Reaction, loc *locale.Locale) string {
+	switch {
+	case r.Emoji != "":
+		return r.Emoji
+	case r.Type == "paid":
+		return "⭐"
+	}
+	return "[" + loc.T("custom_emoji") + "]"
+}
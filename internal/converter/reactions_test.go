@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func sampleReactions() []parser.Reaction {
+	return []parser.Reaction{
+		{Type: "emoji", Count: 3, Emoji: "👍", Recent: []parser.ReactionUser{
+			{From: "Иван"}, {From: "Мария"},
+		}},
+		{Type: "emoji", Count: 1, Emoji: "🔥", Recent: []parser.ReactionUser{{From: "Пётр"}}},
+		{Type: "custom_emoji", Count: 2, DocumentID: "5368324170671202286"},
+		{Type: "paid", Count: 10},
+	}
+}
+
+func TestFormatReactions(t *testing.T) {
+	tests := []struct {
+		mode ReactionMode
+		want string
+	}{
+		{ReactionsNone, ""},
+		{"", ""},
+		{ReactionsSummary, "👍3 🔥1 [эмодзи]2 ⭐10"},
+		{ReactionsFull, "👍3 (Иван, Мария, …) 🔥1 (Пётр) [эмодзи]2 ⭐10"},
+	}
+
+	for _, tt := range tests {
+		if got := formatReactions(sampleReactions(), tt.mode, locale.Default()); got != tt.want {
+			t.Errorf("formatReactions(%q) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestConvertMessage_EditAndReactions(t *testing.T) {
+	msg := &parser.Message{
+		ID:        1,
+		Type:      "message",
+		Date:      "2024-01-15T14:30:00",
+		From:      "Иван",
+		Text:      parser.TextContent{Plain: "Привет"},
+		Edited:    "2024-01-15T15:02:00",
+		Reactions: sampleReactions()[:2],
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "defaults hide extras",
+			opts: Options{},
+			want: "[2024-01-15 14:30] Иван: Привет",
+		},
+		{
+			name: "edits only",
+			opts: Options{ShowEdits: true},
+			want: "[2024-01-15 14:30] Иван: Привет (изм. 2024-01-15 15:02)",
+		},
+		{
+			name: "edits and reactions",
+			opts: Options{ShowEdits: true, Reactions: ReactionsSummary},
+			want: "[2024-01-15 14:30] Иван: Привет (изм. 2024-01-15 15:02) 👍3 🔥1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := NewWithOptions(tt.opts).ConvertMessage(msg)
+			if err != nil {
+				t.Fatalf("ConvertMessage failed: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("ConvertMessage() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReactionMode(t *testing.T) {
+	for _, name := range []string{"none", "summary", "full"} {
+		if _, err := ParseReactionMode(name); err != nil {
+			t.Errorf("ParseReactionMode(%q) error: %v", name, err)
+		}
+	}
+	if _, err := ParseReactionMode("all"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
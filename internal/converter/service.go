@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+)
+
+// describeAction renders a service action as a sentence, e.g. "Иван
+// добавил(а) в группу: Анна, Пётр". Unknown actions fall back to the actor and the
+// raw action code. esc escapes names and titles, pinned renders the
+// reference to a pinned message.
+func describeAction(msg *parser.Message, loc *locale.Locale, esc func(string) string,
+	pinned func(id int64) string) string {
+	actor := msg.Actor
+	if actor == "" {
+		actor = msg.From
+	}
+	actor = userName(actor, loc, esc)
+	title := esc(sanitizer.SanitizeText(msg.Title))
+
+	switch msg.Action {
+	case "create_group", "topic_created":
+		return loc.T("action."+msg.Action, actor, title)
+	case "create_channel", "migrate_from_group", "edit_group_title":
+		return loc.T("action."+msg.Action, title)
+	case "migrate_to_supergroup", "clear_history":
+		return loc.T("action." + msg.Action)
+	case "invite_members", "remove_members":
+		if len(msg.Members) == 0 {
+			break
+		}
+		// Joining and leaving are recorded as adding or removing oneself
+		if len(msg.Members) == 1 && msg.Members[0] == msg.Actor {
+			if msg.Action == "invite_members" {
+				return loc.T("action.joined", actor)
+			}
+			return loc.T("action.left", actor)
+		}
+		return loc.T("action."+msg.Action, actor, members(msg.Members, loc, esc))
+	case "invite_to_group_call":
+		if len(msg.Members) == 0 {
+			break
+		}
+		return loc.T("action."+msg.Action, actor, members(msg.Members, loc, esc))
+	case "join_group_by_link":
+		if msg.Inviter == "" {
+			return loc.T("action.joined", actor)
+		}
+		return loc.T("action."+msg.Action, actor, userName(msg.Inviter, loc, esc))
+	case "join_group_by_request", "edit_group_photo", "delete_group_photo":
+		return loc.T("action."+msg.Action, actor)
+	case "pin_message":
+		// Older exports do not say which message was pinned
+		ref := ""
+		if msg.MessageID != 0 {
+			ref = " " + pinned(msg.MessageID)
+		}
+		return loc.T("action."+msg.Action, actor, ref)
+	case "phone_call", "group_call":
+		return describeCall(msg, loc)
+	case "topic_edit":
+		// Edits that only change the icon have no title
+		if msg.NewTitle == "" {
+			return loc.T("action.topic_edit", actor)
+		}
+		return loc.T("action.topic_renamed", actor, esc(sanitizer.SanitizeText(msg.NewTitle)))
+	case "set_messages_ttl":
+		if msg.Period == 0 {
+			return loc.T("action.messages_ttl_off", actor)
+		}
+		return loc.T("action."+msg.Action, actor, formatPeriod(msg.Period, loc))
+	case "group_call_scheduled":
+		date, _, err := formatTimestamp(msg.ScheduleDate)
+		if err != nil {
+			break
+		}
+		return loc.T("action."+msg.Action, actor, date)
+	case "score_in_game":
+		return loc.T("action."+msg.Action, actor, loc.N(msg.Score, "points"))
+	case "edit_chat_theme":
+		if msg.Emoticon == "" {
+			return loc.T("action.chat_theme_off", actor)
+		}
+		return loc.T("action."+msg.Action, actor, msg.Emoticon)
+	case "take_screenshot":
+		return loc.T("action."+msg.Action, actor)
+	case "send_payment":
+		return loc.T("action."+msg.Action, actor, formatAmount(msg.Amount, msg.Currency))
+	case "boost_apply":
+		return loc.T("action."+msg.Action, actor, loc.N(max(msg.Boosts, 1), "boosts"))
+	case "custom_action":
+		if msg.InformationText == "" {
+			break
+		}
+		return esc(sanitizer.SanitizeText(msg.InformationText))
+	}
+	return fmt.Sprintf("%s %s", actor, msg.Action)
+}
+
+// describeCall renders a call with its duration and how it ended, e.g.
+// "Звонок 12:03, отклонён".
+func describeCall(msg *parser.Message, loc *locale.Locale) string {
+	text := loc.T("action." + msg.Action)
+	if msg.DurationSeconds > 0 {
+		text += " " + formatDuration(msg.DurationSeconds)
+	}
+	switch msg.DiscardReason {
+	case "missed", "busy", "disconnect":
+		text += ", " + loc.T("call."+msg.DiscardReason)
+	}
+	return text
+}
+
+// formatPeriod renders an auto-delete period in whole days, hours or
+// minutes, e.g. "7 дней".
+func formatPeriod(seconds int, loc *locale.Locale) string {
+	switch {
+	case seconds%86400 == 0:
+		return loc.N(seconds/86400, "days")
+	case seconds%3600 == 0:
+		return loc.N(seconds/3600, "hours")
+	}
+	return loc.N(max(seconds/60, 1), "minutes")
+}
+
+// zeroDecimal lists currencies without minor units. Payment amounts in
+// other currencies are in hundredths.
+var zeroDecimal = map[string]bool{
+	"CLP": true, "ISK": true, "JPY": true, "KRW": true,
+	"PYG": true, "UGX": true, "VND": true, "XTR": true,
+}
+
+// formatAmount renders a payment amount given in minor units, e.g.
+// "12.50 USD".
+func formatAmount(amount int64, currency string) string {
+	if zeroDecimal[currency] {
+		return fmt.Sprintf("%d %s", amount, currency)
+	}
+	return fmt.Sprintf("%d.%02d %s", amount/100, amount%100, currency)
+}
+
+// members lists member names; deleted accounts have no name in the export.
+func members(names []string, loc *locale.Locale, esc func(string) string) string {
+	parts := make([]string, len(names))
+	for i, member := range names {
+		parts[i] = userName(member, loc, esc)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// userName escapes a user name, naming missing ones "Unknown".
+func userName(user string, loc *locale.Locale, esc func(string) string) string {
+	if user = sanitizer.SanitizeText(user); user == "" {
+		return loc.T("unknown")
+	}
+	return esc(user)
+}
+
+// plainRef renders the number of a pinned message without a link.
+func plainRef(id int64) string {
+	return fmt.Sprintf("#%d", id)
+}
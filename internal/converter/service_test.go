@@ -0,0 +1,190 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestDescribeAction(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "invite members",
+			msg:  parser.Message{Actor: "Иван", Action: "invite_members", Members: []string{"Анна", "Пётр"}},
+			want: "Иван добавил(а) в группу: Анна, Пётр",
+		},
+		{
+			name: "deleted member",
+			msg:  parser.Message{Actor: "Иван", Action: "remove_members", Members: []string{""}},
+			want: "Иван удалил(а) из группы: Unknown",
+		},
+		{
+			name: "joined",
+			msg:  parser.Message{Actor: "Анна", Action: "invite_members", Members: []string{"Анна"}},
+			want: "Анна вступил(а) в группу",
+		},
+		{
+			name: "left",
+			msg:  parser.Message{Actor: "Анна", Action: "remove_members", Members: []string{"Анна"}},
+			want: "Анна покинул(а) группу",
+		},
+		{
+			name: "join by link",
+			msg:  parser.Message{Actor: "Пётр", Action: "join_group_by_link", Inviter: "Group"},
+			want: "Пётр вступил(а) по ссылке, автор ссылки: Group",
+		},
+		{
+			name: "pin",
+			msg:  parser.Message{Actor: "Мария", Action: "pin_message", MessageID: 512},
+			want: "Мария закрепил(а) сообщение #512",
+		},
+		{
+			name: "title",
+			msg:  parser.Message{Actor: "Мария", Action: "edit_group_title", Title: "Новый *чат*"},
+			want: "Название изменено на «Новый \\*чат\\*»",
+		},
+		{
+			name: "declined call",
+			msg:  parser.Message{Actor: "Иван", Action: "phone_call", DurationSeconds: 723, DiscardReason: "busy"},
+			want: "Звонок 12:03, отклонён",
+		},
+		{
+			name: "finished call",
+			msg:  parser.Message{Actor: "Иван", Action: "phone_call", DiscardReason: "hangup"},
+			want: "Звонок",
+		},
+		{
+			name: "topic renamed",
+			msg:  parser.Message{Actor: "Мария", Action: "topic_edit", NewTitle: "Релизы"},
+			want: "Мария переименовал(а) тему в «Релизы»",
+		},
+		{
+			name: "topic icon",
+			msg:  parser.Message{Actor: "Мария", Action: "topic_edit"},
+			want: "Мария изменил(а) тему",
+		},
+		{
+			name: "auto-delete",
+			msg:  parser.Message{Actor: "Иван", Action: "set_messages_ttl", Period: 604800},
+			want: "Иван включил(а) автоудаление сообщений через 7 дней",
+		},
+		{
+			name: "auto-delete off",
+			msg:  parser.Message{Actor: "Иван", Action: "set_messages_ttl"},
+			want: "Иван отключил(а) автоудаление сообщений",
+		},
+		{
+			name: "scheduled call",
+			msg:  parser.Message{Actor: "Иван", Action: "group_call_scheduled", ScheduleDate: "2024-03-01T19:00:00"},
+			want: "Иван запланировал(а) видеочат на 2024-03-01 19:00",
+		},
+		{
+			name: "game score",
+			msg:  parser.Message{Actor: "Пётр", Action: "score_in_game", Score: 42},
+			want: "Пётр набрал(а) в игре 42 очка",
+		},
+		{
+			name: "chat theme",
+			msg:  parser.Message{Actor: "Анна", Action: "edit_chat_theme", Emoticon: "🌸"},
+			want: "Анна изменил(а) тему чата на 🌸",
+		},
+		{
+			name: "chat theme off",
+			msg:  parser.Message{Actor: "Анна", Action: "edit_chat_theme"},
+			want: "Анна отключил(а) тему чата",
+		},
+		{
+			name: "screenshot",
+			msg:  parser.Message{Actor: "Анна", Action: "take_screenshot"},
+			want: "Анна сделал(а) снимок экрана",
+		},
+		{
+			name: "payment",
+			msg:  parser.Message{Actor: "Иван", Action: "send_payment", Amount: 1250, Currency: "USD"},
+			want: "Иван оплатил(а) 12.50 USD",
+		},
+		{
+			name: "payment in stars",
+			msg:  parser.Message{Actor: "Иван", Action: "send_payment", Amount: 50, Currency: "XTR"},
+			want: "Иван оплатил(а) 50 XTR",
+		},
+		{
+			name: "boost",
+			msg:  parser.Message{Actor: "Иван", Action: "boost_apply", Boosts: 2},
+			want: "Иван применил(а) к группе 2 буста",
+		},
+		{
+			name: "custom action",
+			msg:  parser.Message{Actor: "Бот", Action: "custom_action", InformationText: "Бот *обновлён*"},
+			want: "Бот \\*обновлён\\*",
+		},
+		{
+			name: "unknown action",
+			msg:  parser.Message{Actor: "Иван", Action: "gift_premium"},
+			want: "Иван gift_premium",
+		},
+		{
+			name: "no members",
+			msg:  parser.Message{Actor: "Иван", Action: "invite_members"},
+			want: "Иван invite_members",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeAction(&tt.msg, locale.Default(), escapeInline, plainRef); got != tt.want {
+				t.Errorf("describeAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeAction_English(t *testing.T) {
+	en, _ := locale.Get("en")
+	tests := []struct {
+		msg  parser.Message
+		want string
+	}{
+		{parser.Message{Actor: "Maria", Action: "topic_edit", NewTitle: "Releases"}, "Maria renamed the topic to “Releases”"},
+		{parser.Message{Actor: "John", Action: "set_messages_ttl", Period: 86400}, "John set messages to auto-delete after 1 day"},
+		{parser.Message{Actor: "John", Action: "boost_apply", Boosts: 3}, "John applied 3 boosts to the group"},
+	}
+	for _, tt := range tests {
+		if got := describeAction(&tt.msg, en, escapeInline, plainRef); got != tt.want {
+			t.Errorf("describeAction(%s) = %q, want %q", tt.msg.Action, got, tt.want)
+		}
+	}
+}
+
+func TestConvertMessage_PinLinksMessage(t *testing.T) {
+	c := NewWithOptions(Options{
+		Anchors: true,
+		ReplyLink: func(from, to int64, _ time.Time, _ string) (string, bool) {
+			return "chat_январь_2024.md#msg-512", to == 512
+		},
+	})
+	msg := &parser.Message{
+		ID:        600,
+		Type:      "service",
+		Date:      "2024-02-01T09:00:00",
+		Actor:     "Мария",
+		Action:    "pin_message",
+		MessageID: 512,
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := `<a id="msg-600"></a>[2024-02-01 09:00] [Служебное: Мария закрепил(а) сообщение [#512](chat_январь_2024.md#msg-512)]`
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
@@ -0,0 +1,109 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
+)
+
+// formatStructured renders polls, locations, venues, contacts, dice and
+// games. Returns an empty string when the message has none of them.
+// esc escapes user-provided text.
+func formatStructured(msg *parser.Message, loc *locale.Locale, esc func(string) string) string {
+	switch {
+	case msg.Poll != nil:
+		return formatPoll(msg.Poll, loc, esc)
+	case msg.LocationInformation != nil:
+		return formatLocation(msg, loc, esc)
+	case msg.ContactInformation != nil:
+		return formatContact(msg.ContactInformation, loc, esc)
+	case msg.Dice != nil:
+		return fmt.Sprintf("[%s %s: %d]", loc.T("dice"), msg.Dice.Emoji, msg.Dice.Value)
+	case msg.GameTitle != "":
+		label := fmt.Sprintf("[%s: %s]", loc.T("game"), esc(sanitizer.SanitizeText(msg.GameTitle)))
+		if msg.GameDescription != "" {
+			label += " " + esc(sanitizer.SanitizeText(msg.GameDescription))
+		}
+		if msg.GameLink != "" {
+			label += " " + msg.GameLink
+		}
+		return label
+	}
+	return ""
+}
+
+// formatPoll renders a poll as a header line followed by one line per option.
+func formatPoll(poll *parser.Poll, loc *locale.Locale, esc func(string) string) string {
+	var builder strings.Builder
+
+	builder.WriteString("[" + loc.T("poll") + ": ")
+	builder.WriteString(esc(sanitizer.SanitizeText(poll.Question)))
+	builder.WriteString("]")
+	if poll.Closed {
+		builder.WriteString(" (" + loc.T("poll.closed") + ")")
+	}
+
+	for _, answer := range poll.Answers {
+		builder.WriteString("\n- ")
+		builder.WriteString(esc(sanitizer.SanitizeText(answer.Text)))
+		builder.WriteString(" — ")
+		builder.WriteString(loc.N(answer.Voters, "votes"))
+		if answer.Chosen {
+			builder.WriteString(" ✓")
+		}
+	}
+
+	builder.WriteString("\n" + loc.T("poll.total") + ": ")
+	builder.WriteString(loc.N(poll.TotalVoters, "votes"))
+
+	return builder.String()
+}
+
+// formatLocation renders a location or venue as a geo: link.
+func formatLocation(msg *parser.Message, loc *locale.Locale, esc func(string) string) string {
+	info := msg.LocationInformation
+	coords := formatCoordinate(info.Latitude) + "," + formatCoordinate(info.Longitude)
+
+	var label string
+	switch {
+	case msg.PlaceName != "" || msg.Address != "":
+		parts := []string{}
+		for _, part := range []string{msg.PlaceName, msg.Address} {
+			if part != "" {
+				parts = append(parts, esc(sanitizer.SanitizeText(part)))
+			}
+		}
+		label = loc.T("venue") + ": " + strings.Join(parts, ", ")
+	case msg.LiveLocationPeriodSeconds > 0:
+		label = loc.T("live_location") + " " + formatDuration(msg.LiveLocationPeriodSeconds)
+	default:
+		label = loc.T("location") + ": " + strings.ReplaceAll(coords, ",", ", ")
+	}
+
+	return fmt.Sprintf("[%s](geo:%s)", label, coords)
+}
+
+// formatContact renders a shared contact with name and phone.
+func formatContact(contact *parser.ContactInformation, loc *locale.Locale, esc func(string) string) string {
+	name := strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	parts := []string{}
+	if name != "" {
+		parts = append(parts, esc(sanitizer.SanitizeText(name)))
+	}
+	if contact.PhoneNumber != "" {
+		parts = append(parts, contact.PhoneNumber)
+	}
+	if len(parts) == 0 {
+		return "[" + loc.T("contact") + "]"
+	}
+	return fmt.Sprintf("[%s: %s]", loc.T("contact"), strings.Join(parts, ", "))
+}
+
+// formatCoordinate formats a coordinate without trailing zeros.
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
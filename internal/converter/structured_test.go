@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func TestFormatStructured(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  parser.Message
+		want string
+	}{
+		{
+			name: "poll",
+			msg: parser.Message{Poll: &parser.Poll{
+				Question:    "Куда идём?",
+				Closed:      true,
+				TotalVoters: 5,
+				Answers: []parser.PollAnswer{
+					{Text: "Кино", Voters: 3, Chosen: true},
+					{Text: "Театр", Voters: 2},
+					{Text: "Домой", Voters: 0},
+				},
+			}},
+			want: "[Опрос: Куда идём?] (закрыт)\n" +
+				"- Кино — 3 голоса ✓\n" +
+				"- Театр — 2 голоса\n" +
+				"- Домой — 0 голосов\n" +
+				"Всего: 5 голосов",
+		},
+		{
+			name: "location",
+			msg: parser.Message{LocationInformation: &parser.LocationInformation{
+				Latitude: 55.751244, Longitude: 37.618423,
+			}},
+			want: "[Геопозиция: 55.751244, 37.618423](geo:55.751244,37.618423)",
+		},
+		{
+			name: "live location",
+			msg: parser.Message{
+				LocationInformation:       &parser.LocationInformation{Latitude: 55.75, Longitude: 37.6},
+				LiveLocationPeriodSeconds: 900,
+			},
+			want: "[Трансляция геопозиции 15:00](geo:55.75,37.6)",
+		},
+		{
+			name: "venue",
+			msg: parser.Message{
+				LocationInformation: &parser.LocationInformation{Latitude: 59.9386, Longitude: 30.3141},
+				PlaceName:           "Эрмитаж",
+				Address:             "Дворцовая пл., 2",
+			},
+			want: "[Место: Эрмитаж, Дворцовая пл., 2](geo:59.9386,30.3141)",
+		},
+		{
+			name: "contact",
+			msg: parser.Message{ContactInformation: &parser.ContactInformation{
+				FirstName: "Мария", LastName: "Иванова", PhoneNumber: "+79991234567",
+			}},
+			want: "[Контакт: Мария Иванова, +79991234567]",
+		},
+		{
+			name: "dice",
+			msg:  parser.Message{Dice: &parser.Dice{Emoji: "🎲", Value: 4}},
+			want: "[Кубик 🎲: 4]",
+		},
+		{
+			name: "game",
+			msg:  parser.Message{GameTitle: "Lumberjack", GameLink: "https://t.me/gamebot?game=lumberjack"},
+			want: "[Игра: Lumberjack] https://t.me/gamebot?game=lumberjack",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatStructured(&tt.msg, locale.Default(), escapeInline); got != tt.want {
+				t.Errorf("formatStructured() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMessage_PollIsNotEmpty(t *testing.T) {
+	c := New()
+	msg := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "Иван",
+		Poll: &parser.Poll{Question: "Да?", Answers: []parser.PollAnswer{{Text: "Да", Voters: 1}}, TotalVoters: 1},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "[2024-01-15 14:30] Иван: [Опрос: Да?]\n- Да — 1 голос\nВсего: 1 голос"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
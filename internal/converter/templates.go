@@ -0,0 +1,179 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+)
+
+// DefaultTemplate renders messages in the standard tg2md format. Labels
+// come from the locale catalog through the "t" function. Continued
+// forwards keep only a "↪ author:" header, the time and source are those
+// of their batch.
+const DefaultTemplate = `{{.Anchor}}` +
+	`{{if .Service}}[{{.Timestamp}}] [{{t "service"}}: {{.Text}}]` +
+	`{{else}}{{if .Continued}}↪ {{else}}[{{.Timestamp}}] {{end}}{{.Author}}: ` +
+	`{{with .Reply}}[{{t "reply"}}: "{{.Preview}}"]{{with .Link}}({{.}}){{end}} {{end}}` +
+	`{{if and .Forward (not .Continued)}}` +
+	`[{{if .Forward.Saved}}{{t "saved_from"}}{{else}}{{t "forwarded"}}{{end}}: {{.Forward}}] {{end}}` +
+	`{{.Media}}{{if and .Media .Text}} {{end}}{{.Text}}` +
+	`{{with .Edited}} ({{t "edited"}} {{.}}){{end}}{{with .Reactions}} {{.}}{{end}}` +
+	`{{end}}`
+
+// Template names looked up in a template file.
+const (
+	messageTemplate = "message"
+	headerTemplate  = "header"
+	footerTemplate  = "footer"
+)
+
+// MessageView is the data a message template receives. Text fields are
+// already escaped for Markdown.
+type MessageView struct {
+	ID        int64
+	Time      time.Time
+	Timestamp string // "2006-01-02 15:04"
+	Anchor    string // HTML anchor, empty when anchors are off
+	Author    string
+	AuthorID  string
+	Text      string
+	Media     string
+	Forward   *ForwardView
+	Reply     *ReplyView
+	Edited    string // edit time, empty when not edited or hidden
+	Reactions string
+
+	// Service messages describe the event in Text. Actor and the raw
+	// Action code are empty when the export describes it only in words
+	Service bool
+	Actor   string
+	Action  string
+
+	// Continued marks a forward that continues a batch forwarded from the
+	// same source by the same author in the same minute
+	Continued bool
+}
+
+// ForwardView describes where a forwarded message comes from. It prints
+// as the source with the original date, e.g. "Канал X, 2023-11-02".
+type ForwardView struct {
+	From   string // escaped source name
+	FromID string // e.g. "channel1234", empty in older exports
+	Date   string // original date "2006-01-02", empty when unknown
+	// Saved is set for messages saved to Saved Messages from a chat
+	Saved bool
+}
+
+// String renders the source with the original date.
+func (f *ForwardView) String() string {
+	if f.Date == "" {
+		return f.From
+	}
+	return f.From + ", " + f.Date
+}
+
+// ReplyView describes the message a reply refers to.
+type ReplyView struct {
+	ID      int64
+	Preview string // "..." when the original is unknown
+	Link    string // empty when the original's location is unknown
+}
+
+// FileView is the data header and footer templates receive.
+type FileView struct {
+	Chat   string
+	Topic  string    // forum topic title, empty for the main chat files
+	Period string    // e.g. "january_2024", empty when not split
+	Start  time.Time // time of the first message in the file
+}
+
+// Templates holds parsed message, header and footer templates.
+type Templates struct {
+	message *template.Template
+	header  *template.Template
+	footer  *template.Template
+}
+
+// DefaultTemplates returns the built-in templates without header and
+// footer, labelled in loc.
+func DefaultTemplates(loc *locale.Locale) *Templates {
+	return &Templates{
+		message: template.Must(template.New(messageTemplate).Funcs(templateFuncs(loc)).Parse(DefaultTemplate)),
+	}
+}
+
+// LoadTemplates parses a template file. The file may define "message",
+// "header" and "footer" templates; without a "message" definition the
+// whole file is the message template. Template functions use loc.
+func LoadTemplates(path string, loc *locale.Locale) (*Templates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+
+	// Files are usually saved with a final newline, which is not part of
+	// the message line
+	root, err := template.New(messageTemplate).Funcs(templateFuncs(loc)).Parse(strings.TrimSuffix(string(data), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	t := &Templates{
+		message: root.Lookup(messageTemplate),
+		header:  root.Lookup(headerTemplate),
+		footer:  root.Lookup(footerTemplate),
+	}
+	// A file holding only header or footer definitions keeps the
+	// default message format
+	if t.message.Tree == nil || strings.TrimSpace(t.message.Tree.Root.String()) == "" {
+		t.message = DefaultTemplates(loc).message
+	}
+	return t, nil
+}
+
+// templateFuncs returns the functions available to templates:
+//
+//	t             catalog label, e.g. {{t "reply"}}
+//	month         nominative month name of a time, e.g. "январь"
+//	monthGenitive month name as used with a day, e.g. "января"
+//	date          day with month name, e.g. "15 января 2024"
+func templateFuncs(loc *locale.Locale) template.FuncMap {
+	return template.FuncMap{
+		"t": loc.T,
+		"month": func(t time.Time) string {
+			return loc.Month(t.Month())
+		},
+		"monthGenitive": func(t time.Time) string {
+			return loc.MonthGenitive(t.Month())
+		},
+		"date": loc.Date,
+	}
+}
+
+// Header renders the header of a new output file. Returns an empty string
+// when the templates define no header.
+func (t *Templates) Header(file FileView) (string, error) {
+	return execute(t.header, file)
+}
+
+// Footer renders the footer of a finished output file. Returns an empty
+// string when the templates define no footer.
+func (t *Templates) Footer(file FileView) (string, error) {
+	return execute(t.footer, file)
+}
+
+// execute renders tmpl, treating a missing template as empty output.
+func execute(tmpl *template.Template, data any) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", tmpl.Name(), err)
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "message.tmpl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestLoadTemplates_Message(t *testing.T) {
+	path := writeTemplate(t, "{{.Timestamp}} | {{.Author}} ({{.AuthorID}}){{with .Reply}} ↪ {{.Preview}}{{end}}: {{.Text}}\n")
+
+	templates, err := LoadTemplates(path, locale.Default())
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	c := NewWithOptions(Options{Templates: templates})
+
+	c.CacheMessage(1, "Привет")
+	replyTo := int64(1)
+	msg := &parser.Message{
+		ID:           2,
+		Type:         "message",
+		Date:         "2024-01-15T14:31:00",
+		From:         "Мария",
+		FromID:       "user42",
+		ReplyToMsgID: &replyTo,
+		Text:         parser.TextContent{Plain: "Отлично"},
+	}
+
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+
+	expected := "2024-01-15 14:31 | Мария (user42) ↪ Привет: Отлично"
+	if result != expected {
+		t.Errorf("ConvertMessage() = %q, want %q", result, expected)
+	}
+}
+
+func TestLoadTemplates_HeaderAndFooterOnly(t *testing.T) {
+	path := writeTemplate(t, `{{define "header"}}# {{.Chat}}{{with .Topic}} / {{.}}{{end}}, {{.Period}}
+{{end}}
+{{define "footer"}}-- {{.Period}}
+{{end}}
+`)
+
+	templates, err := LoadTemplates(path, locale.Default())
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+
+	header, err := templates.Header(FileView{Chat: "Чат", Topic: "Релизы", Period: "january_2024"})
+	if err != nil {
+		t.Fatalf("Header failed: %v", err)
+	}
+	if header != "# Чат / Релизы, january_2024\n" {
+		t.Errorf("Header() = %q", header)
+	}
+
+	footer, err := templates.Footer(FileView{Chat: "Чат", Period: "january_2024"})
+	if err != nil {
+		t.Fatalf("Footer failed: %v", err)
+	}
+	if footer != "-- january_2024\n" {
+		t.Errorf("Footer() = %q", footer)
+	}
+
+	// Messages keep the default format
+	c := NewWithOptions(Options{Templates: templates})
+	msg := &parser.Message{
+		ID:   1,
+		Type: "message",
+		Date: "2024-01-15T14:30:00",
+		From: "Иван",
+		Text: parser.TextContent{Plain: "Привет"},
+	}
+	result, _, err := c.ConvertMessage(msg)
+	if err != nil {
+		t.Fatalf("ConvertMessage failed: %v", err)
+	}
+	if result != "[2024-01-15 14:30] Иван: Привет" {
+		t.Errorf("ConvertMessage() = %q", result)
+	}
+}
+
+func TestDefaultTemplates_NoFrame(t *testing.T) {
+	templates := DefaultTemplates(locale.Default())
+
+	if header, err := templates.Header(FileView{Chat: "Чат"}); err != nil || header != "" {
+		t.Errorf("Header() = %q, %v, want empty", header, err)
+	}
+	if footer, err := templates.Footer(FileView{Chat: "Чат"}); err != nil || footer != "" {
+		t.Errorf("Footer() = %q, %v, want empty", footer, err)
+	}
+}
+
+func TestLoadTemplates_Errors(t *testing.T) {
+	if _, err := LoadTemplates(writeTemplate(t, "{{.Author"), locale.Default()); err == nil {
+		t.Error("LoadTemplates should fail on a syntax error")
+	}
+	if _, err := LoadTemplates(filepath.Join(t.TempDir(), "missing.tmpl"), locale.Default()); err == nil {
+		t.Error("LoadTemplates should fail on a missing file")
+	}
+
+	templates, err := LoadTemplates(writeTemplate(t, "{{.Unknown}}"), locale.Default())
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	c := NewWithOptions(Options{Templates: templates})
+	msg := &parser.Message{ID: 1, Type: "message", Date: "2024-01-15T14:30:00", Text: parser.TextContent{Plain: "x"}}
+	if _, _, err := c.ConvertMessage(msg); err == nil {
+		t.Error("ConvertMessage should report unknown template fields")
+	}
+}
+
+func TestLoadTemplates_LocaleFuncs(t *testing.T) {
+	path := writeTemplate(t, `{{define "header"}}# {{t "topic" 3}}, {{month .Start}}: {{date .Start}}{{end}}`)
+	start := time.Date(2024, time.January, 15, 14, 30, 0, 0, time.UTC)
+
+	en, _ := locale.Get("en")
+	tests := []struct {
+		loc  *locale.Locale
+		want string
+	}{
+		{locale.Default(), "# Тема 3, январь: 15 января 2024"},
+		{en, "# Topic 3, january: January 15, 2024"},
+	}
+	for _, tt := range tests {
+		templates, err := LoadTemplates(path, tt.loc)
+		if err != nil {
+			t.Fatalf("LoadTemplates failed: %v", err)
+		}
+		header, err := templates.Header(FileView{Chat: "Чат", Start: start})
+		if err != nil {
+			t.Fatalf("Header failed: %v", err)
+		}
+		if header != tt.want {
+			t.Errorf("Header() = %q, want %q", header, tt.want)
+		}
+	}
+}
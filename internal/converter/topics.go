@@ -0,0 +1,50 @@
+package converter
+
+import (
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// topicCreatedAction is the service action that opens a forum topic.
+const topicCreatedAction = "topic_created"
+
+// Topic returns the forum topic a converted message belongs to.
+// ok is false for messages outside any topic (the General topic).
+func (c *Converter) Topic(msgID int64) (id int64, title string, ok bool) {
+	id, ok = c.topicOf[msgID]
+	if !ok {
+		return 0, "", false
+	}
+	return id, c.topics[id], true
+}
+
+// trackTopic records forum topic membership for msg.
+// In forum exports every topic message replies to the topic_created
+// service message; trackTopic reports such replies so they are not
+// rendered as real replies.
+func (c *Converter) trackTopic(msg *parser.Message) (topicReply bool) {
+	if msg.Action == topicCreatedAction {
+		title := msg.Title
+		if title == "" {
+			title = c.locale.T("topic", msg.ID)
+		}
+		c.topics[msg.ID] = title
+		c.topicOf[msg.ID] = msg.ID
+		return false
+	}
+
+	if msg.ReplyToMsgID == nil {
+		return false
+	}
+	target := *msg.ReplyToMsgID
+
+	if _, ok := c.topics[target]; ok {
+		c.topicOf[msg.ID] = target
+		return true
+	}
+
+	// A real reply inside a topic stays in the target's topic
+	if topic, ok := c.topicOf[target]; ok {
+		c.topicOf[msg.ID] = topic
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestConvertMessage_ForumTopics(t *testing.T) {
+	c := New()
+	messages := []*parser.Message{
+		{ID: 1, Type: "message", Date: "2024-01-15T10:00:00", From: "Иван", Text: parser.TextContent{Plain: "в общем"}},
+		{ID: 2, Type: "service", Date: "2024-01-15T10:01:00", Actor: "Иван", Action: "topic_created", Title: "Релизы"},
+		{ID: 3, Type: "message", Date: "2024-01-15T10:02:00", From: "Мария", ReplyToMsgID: int64Ptr(2), Text: parser.TextContent{Plain: "выкатили 1.2"}},
+		{ID: 4, Type: "message", Date: "2024-01-15T10:03:00", From: "Пётр", ReplyToMsgID: int64Ptr(3), Text: parser.TextContent{Plain: "ура"}},
+		{ID: 5, Type: "message", Date: "2024-01-15T10:04:00", From: "Анна", ReplyToMsgID: int64Ptr(1), Text: parser.TextContent{Plain: "ответ"}},
+	}
+
+	var results []string
+	for _, msg := range messages {
+		result, _, err := c.ConvertMessage(msg)
+		if err != nil {
+			t.Fatalf("ConvertMessage(%d) failed: %v", msg.ID, err)
+		}
+		results = append(results, result)
+	}
+
+	// Topic root reply is not rendered as a reply
+	if want := "[2024-01-15 10:02] Мария: выкатили 1.2"; results[2] != want {
+		t.Errorf("topic message = %q, want %q", results[2], want)
+	}
+	// Real reply inside a topic keeps its prefix
+	if want := "[2024-01-15 10:03] Пётр: [В ответ на: \"выкатили 1.2\"] ура"; results[3] != want {
+		t.Errorf("topic reply = %q, want %q", results[3], want)
+	}
+
+	tests := []struct {
+		msgID  int64
+		wantID int64
+		wantOK bool
+	}{
+		{1, 0, false},
+		{2, 2, true},
+		{3, 2, true},
+		{4, 2, true},
+		{5, 0, false},
+	}
+	for _, tt := range tests {
+		id, title, ok := c.Topic(tt.msgID)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("Topic(%d) = %d, %v, want %d, %v", tt.msgID, id, ok, tt.wantID, tt.wantOK)
+		}
+		if ok && title != "Релизы" {
+			t.Errorf("Topic(%d) title = %q, want %q", tt.msgID, title, "Релизы")
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package htmlparser
+
+import (
+	"html"
+	"strings"
+)
+
+// voidElements never have children or closing tags.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold content that must not be parsed as markup.
+var rawTextElements = map[string]bool{
+	"script": true,
+	"style":  true,
+}
+
+// node is an element or text node of a parsed HTML page.
+// Text nodes have an empty tag.
+type node struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	children []*node
+	parent   *node
+}
+
+// parseHTML builds a lenient node tree from an HTML document.
+// It understands just enough HTML for Telegram Desktop exports:
+// unmatched end tags are ignored and open elements are closed implicitly.
+func parseHTML(data string) *node {
+	root := &node{tag: "#root"}
+	current := root
+
+	for i := 0; i < len(data); {
+		if data[i] != '<' {
+			end := strings.IndexByte(data[i:], '<')
+			if end < 0 {
+				end = len(data) - i
+			}
+			current.appendText(html.UnescapeString(data[i : i+end]))
+			i += end
+			continue
+		}
+
+		rest := data[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				return root
+			}
+			i += end + len("-->")
+
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			name := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			for n := current; n != root; n = n.parent {
+				if n.tag == name {
+					current = n.parent
+					break
+				}
+			}
+			i += end + 1
+
+		case len(rest) > 1 && isLetter(rest[1]):
+			el, size, selfClosing := parseTag(rest)
+			if el == nil {
+				current.appendText("<")
+				i++
+				continue
+			}
+			i += size
+
+			el.parent = current
+			current.children = append(current.children, el)
+
+			if rawTextElements[el.tag] {
+				closing := "</" + el.tag
+				end := strings.Index(strings.ToLower(data[i:]), closing)
+				if end < 0 {
+					return root
+				}
+				i += end
+				continue
+			}
+			if !selfClosing && !voidElements[el.tag] {
+				current = el
+			}
+
+		default:
+			current.appendText("<")
+			i++
+		}
+	}
+
+	return root
+}
+
+// parseTag parses a start tag at the beginning of s.
+// Returns the element, the number of bytes consumed and whether the tag
+// was self-closing.
+func parseTag(s string) (*node, int, bool) {
+	i := 1
+	start := i
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	el := &node{
+		tag:   strings.ToLower(s[start:i]),
+		attrs: make(map[string]string),
+	}
+
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, false
+		}
+
+		switch s[i] {
+		case '>':
+			return el, i + 1, false
+		case '/':
+			if i+1 < len(s) && s[i+1] == '>' {
+				return el, i + 2, true
+			}
+			i++
+			continue
+		}
+
+		// Attribute name
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) || s[i] != '=' {
+			el.attrs[name] = ""
+			continue
+		}
+		i++
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, false
+		}
+
+		// Attribute value
+		var value string
+		if q := s[i]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[i+1:], q)
+			if end < 0 {
+				return nil, 0, false
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+				i++
+			}
+			value = s[start:i]
+		}
+		el.attrs[name] = html.UnescapeString(value)
+	}
+
+	return nil, 0, false
+}
+
+// appendText adds text to the node, merging with a trailing text node.
+func (n *node) appendText(text string) {
+	if text == "" {
+		return
+	}
+	if last := len(n.children) - 1; last >= 0 && n.children[last].tag == "" {
+		n.children[last].text += text
+		return
+	}
+	n.children = append(n.children, &node{text: text, parent: n})
+}
+
+// hasClass reports whether the element's class attribute contains c.
+func (n *node) hasClass(c string) bool {
+	for _, class := range strings.Fields(n.attrs["class"]) {
+		if class == c {
+			return true
+		}
+	}
+	return false
+}
+
+// child returns the first direct child element matching the predicate.
+func (n *node) child(match func(*node) bool) *node {
+	for _, c := range n.children {
+		if c.tag != "" && match(c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// find returns the first descendant element matching the predicate,
+// in document order.
+func (n *node) find(match func(*node) bool) *node {
+	for _, c := range n.children {
+		if c.tag == "" {
+			continue
+		}
+		if match(c) {
+			return c
+		}
+		if found := c.find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the concatenated text of the node and its descendants.
+func (n *node) textContent() string {
+	if n.tag == "" {
+		return n.text
+	}
+	var builder strings.Builder
+	for _, c := range n.children {
+		if c.tag == "br" {
+			builder.WriteString("\n")
+			continue
+		}
+		builder.WriteString(c.textContent())
+	}
+	return builder.String()
+}
+
+// withClass returns a predicate matching elements with the given tag and class.
+func withClass(tag, class string) func(*node) bool {
+	return func(n *node) bool {
+		return n.tag == tag && n.hasClass(class)
+	}
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,55 @@
+package htmlparser
+
+import "testing"
+
+func TestParseHTML_NestedElements(t *testing.T) {
+	root := parseHTML(`<div class="a b"><p>one<br/>two</p><span>&lt;three&gt;</span></div>`)
+
+	div := root.find(withClass("div", "b"))
+	if div == nil {
+		t.Fatal("div not found")
+	}
+
+	if got := div.textContent(); got != "one\ntwo<three>" {
+		t.Errorf("textContent() = %q, want %q", got, "one\ntwo<three>")
+	}
+}
+
+func TestParseHTML_Attributes(t *testing.T) {
+	root := parseHTML(`<a href="https://example.com?a=1&amp;b=2" onclick='return X("y")' data-flag>link</a>`)
+
+	a := root.find(func(n *node) bool { return n.tag == "a" })
+	if a == nil {
+		t.Fatal("anchor not found")
+	}
+
+	if got := a.attrs["href"]; got != "https://example.com?a=1&b=2" {
+		t.Errorf("href = %q", got)
+	}
+	if got := a.attrs["onclick"]; got != `return X("y")` {
+		t.Errorf("onclick = %q", got)
+	}
+	if _, ok := a.attrs["data-flag"]; !ok {
+		t.Error("boolean attribute missing")
+	}
+}
+
+func TestParseHTML_SkipsScriptsAndComments(t *testing.T) {
+	root := parseHTML(`<script>if (a < b) { x() }</script><!-- <div>hidden</div> --><div>shown</div>`)
+
+	if got := root.textContent(); got != "shown" {
+		t.Errorf("textContent() = %q, want %q", got, "shown")
+	}
+}
+
+func TestParseHTML_UnmatchedEndTag(t *testing.T) {
+	root := parseHTML(`<div><p>text</span></p></div><div class="next">after</div>`)
+
+	next := root.find(withClass("div", "next"))
+	if next == nil {
+		t.Fatal("sibling div not found")
+	}
+	if next.parent != root {
+		t.Error("sibling div should be a child of the root")
+	}
+}
@@ -0,0 +1,648 @@
+package htmlparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// pagePattern matches export page names: messages.html, messages2.html, ...
+var pagePattern = regexp.MustCompile(`^messages(\d*)\.html$`)
+
+// messageIDPattern extracts a message ID from links like "#go_to_message123"
+// or "messages2.html#go_to_message123".
+var messageIDPattern = regexp.MustCompile(`go_to_message(-?\d+)`)
+
+// Parser reads Telegram Desktop HTML exports page by page.
+type Parser struct {
+	pages    []string
+	chatName string
+
+	// done is closed by Close to stop streams nobody reads anymore,
+	// streams waits for their goroutines
+	done    chan struct{}
+	streams sync.WaitGroup
+}
+
+// New creates a new Parser for an HTML export.
+// path may be the export directory or any of its messages*.html pages.
+func New(path string) (*Parser, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("open export: %w", err)
+	}
+
+	dir := path
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	pages, err := findPages(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no messages*.html pages in %s", dir)
+	}
+
+	return &Parser{pages: pages, done: make(chan struct{})}, nil
+}
+
+// IsExport reports whether path looks like an HTML export:
+// a directory containing messages.html or an .html file.
+func IsExport(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		_, err := os.Stat(filepath.Join(path, "messages.html"))
+		return err == nil
+	}
+	return strings.EqualFold(filepath.Ext(path), ".html")
+}
+
+// GetChatInfo extracts the chat name from the page header of the first page.
+// HTML exports do not record the chat type, so it is always empty.
+func (p *Parser) GetChatInfo() (name, chatType string, err error) {
+	root, err := readPage(p.pages[0])
+	if err != nil {
+		return "", "", err
+	}
+
+	header := root.find(withClass("div", "page_header"))
+	if header != nil {
+		if title := header.find(withClass("div", "text")); title != nil {
+			p.chatName = strings.TrimSpace(title.textContent())
+		}
+	}
+
+	if p.chatName == "" {
+		return "", "", fmt.Errorf("chat name not found in HTML")
+	}
+
+	return p.chatName, "", nil
+}
+
+// StreamMessages returns a channel that yields messages from all pages in order.
+func (p *Parser) StreamMessages() <-chan parser.ParseResult {
+	ch := make(chan parser.ParseResult, 100)
+
+	// send delivers a result unless the parser is closed first
+	send := func(result parser.ParseResult) bool {
+		select {
+		case ch <- result:
+			return true
+		case <-p.done:
+			return false
+		}
+	}
+
+	p.streams.Add(1)
+	go func() {
+		defer p.streams.Done()
+		defer close(ch)
+
+		state := &pageState{}
+		for _, page := range p.pages {
+			root, err := readPage(page)
+			if err != nil {
+				if !send(parser.ParseResult{Error: err}) {
+					return
+				}
+				continue
+			}
+
+			for _, el := range findMessages(root) {
+				msg, err := state.parseMessage(el)
+				switch {
+				case err != nil:
+					err = fmt.Errorf("%s: %w", filepath.Base(page), err)
+					if !send(parser.ParseResult{Error: err}) {
+						return
+					}
+				case msg != nil:
+					if !send(parser.ParseResult{Message: msg}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Close stops the streams that are still running. Pages are read and
+// closed one at a time.
+func (p *Parser) Close() error {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+	p.streams.Wait()
+	return nil
+}
+
+// pageState carries context between messages: joined messages omit the
+// author and service messages omit the date.
+type pageState struct {
+	lastAuthor string
+	lastDate   string
+}
+
+// parseMessage converts a message div to a parser.Message.
+// Returns nil for date separators, which are not messages.
+func (s *pageState) parseMessage(el *node) (*parser.Message, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(el.attrs["id"], "message"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id %q", el.attrs["id"])
+	}
+
+	body := el.child(withClass("div", "body"))
+	if body == nil {
+		return nil, fmt.Errorf("message %d: body not found", id)
+	}
+
+	if el.hasClass("service") {
+		text := strings.TrimSpace(body.textContent())
+		// Date separators carry negative IDs
+		if id < 0 {
+			if t, err := time.Parse("2 January 2006", text); err == nil {
+				s.lastDate = t.Format("2006-01-02T15:04:05")
+			}
+			return nil, nil
+		}
+		// Service messages usually take the date of the last separator,
+		// those before the first one only have their own timestamp if any
+		date := s.lastDate
+		if stamp := el.find(func(n *node) bool { return n.hasClass("date") && n.attrs["title"] != "" }); stamp != nil {
+			parsed, err := parseDateTitle(stamp.attrs["title"])
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", id, err)
+			}
+			date = parsed
+		}
+		if date == "" {
+			return nil, fmt.Errorf("message %d: no date before the first date separator", id)
+		}
+		return &parser.Message{
+			ID:   id,
+			Type: "service",
+			Date: date,
+			Text: parser.TextContent{Plain: text},
+		}, nil
+	}
+
+	msg := &parser.Message{ID: id, Type: "message"}
+
+	if date := body.child(withClass("div", "date")); date != nil {
+		parsed, err := parseDateTitle(date.attrs["title"])
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", id, err)
+		}
+		msg.Date = parsed
+		s.lastDate = parsed
+	}
+
+	// Joined messages continue the previous author's run
+	if from := body.child(withClass("div", "from_name")); from != nil && !el.hasClass("joined") {
+		s.lastAuthor = ownText(from)
+	}
+	msg.From = s.lastAuthor
+
+	if reply := body.child(withClass("div", "reply_to")); reply != nil {
+		if link := reply.find(func(n *node) bool { return n.tag == "a" }); link != nil {
+			if m := messageIDPattern.FindStringSubmatch(link.attrs["href"]); m != nil {
+				replyID, _ := strconv.ParseInt(m[1], 10, 64)
+				msg.ReplyToMsgID = &replyID
+			}
+		}
+	}
+
+	content := body
+	if fwd := body.child(withClass("div", "forwarded")); fwd != nil {
+		if from := fwd.child(withClass("div", "from_name")); from != nil {
+			msg.ForwardedFrom = ownText(from)
+			// The original date is kept in the title of a nested span
+			if date := from.find(withClass("span", "date")); date != nil {
+				if parsed, err := parseDateTitle(date.attrs["title"]); err == nil {
+					msg.ForwardedDate = parsed
+				}
+			}
+		}
+		content = fwd
+	}
+
+	if text := content.child(withClass("div", "text")); text != nil {
+		msg.Text.Entities = trimEntities(convertEntities(text, ""))
+	}
+
+	if media := content.child(withClass("div", "media_wrap")); media != nil {
+		parseMedia(media, msg)
+	}
+
+	if reactions := body.find(func(n *node) bool { return n.hasClass("reactions") }); reactions != nil {
+		msg.Reactions = parseReactions(reactions)
+	}
+
+	return msg, nil
+}
+
+// parseReactions reads emoji reactions and their counts. Reactions listed
+// with userpics instead of a count are counted by their pictures.
+func parseReactions(el *node) []parser.Reaction {
+	var reactions []parser.Reaction
+	for _, r := range el.children {
+		if r.tag == "" || !r.hasClass("reaction") {
+			continue
+		}
+
+		reaction := parser.Reaction{Type: "emoji"}
+		if emoji := r.find(func(n *node) bool { return n.hasClass("emoji") }); emoji != nil {
+			reaction.Emoji = strings.TrimSpace(emoji.textContent())
+		}
+		if reaction.Emoji == "" {
+			reaction.Type = "custom_emoji"
+		}
+
+		if count := r.find(func(n *node) bool { return n.hasClass("count") }); count != nil {
+			reaction.Count = leadingNumber(count.textContent())
+		} else if pics := r.find(func(n *node) bool { return n.hasClass("userpics") }); pics != nil {
+			for _, pic := range pics.children {
+				if pic.tag != "" && pic.hasClass("userpic") {
+					reaction.Count++
+				}
+			}
+		}
+		if reaction.Count == 0 {
+			reaction.Count = 1
+		}
+
+		reactions = append(reactions, reaction)
+	}
+	return reactions
+}
+
+// missingFile mirrors the placeholder JSON exports use for skipped files.
+const missingFile = "(File not included. Change data exporting settings to download.)"
+
+// parseMedia fills media fields from a media_wrap block.
+func parseMedia(wrap *node, msg *parser.Message) {
+	el := wrap.child(func(n *node) bool { return n.tag == "a" || n.tag == "div" })
+	if el == nil {
+		return
+	}
+
+	href := el.attrs["href"]
+	if href == "" {
+		href = missingFile
+	}
+	title := ""
+	if t := el.find(withClass("div", "title")); t != nil {
+		title = strings.TrimSpace(t.textContent())
+	}
+
+	switch {
+	case el.hasClass("photo_wrap"), el.hasClass("media_photo"):
+		msg.Photo = href
+		return
+	case el.hasClass("video_file_wrap"):
+		msg.MediaType = "video_file"
+		if d := el.find(withClass("div", "video_duration")); d != nil {
+			msg.DurationSeconds = parseDuration(d.textContent())
+		}
+	case el.hasClass("animated_wrap"):
+		msg.MediaType = "animation"
+	case el.hasClass("sticker_wrap"):
+		msg.MediaType = "sticker"
+	case el.hasClass("media_voice_message"):
+		msg.MediaType = "voice_message"
+		msg.DurationSeconds = statusDuration(el)
+	case el.hasClass("media_video"):
+		msg.MediaType = "video_message"
+		msg.DurationSeconds = statusDuration(el)
+	case el.hasClass("media_audio_file"):
+		msg.MediaType = "audio_file"
+		msg.FileName = title
+		msg.DurationSeconds = statusDuration(el)
+	case el.hasClass("media_file"):
+		msg.FileName = title
+	case el.hasClass("media_poll"):
+		msg.Poll = parsePoll(el)
+		return
+	case el.hasClass("media_location"), el.hasClass("media_live_location"), el.hasClass("media_venue"):
+		parseLocation(el, title, msg)
+		return
+	case el.hasClass("media_contact"):
+		msg.ContactInformation = &parser.ContactInformation{
+			FirstName:   title,
+			PhoneNumber: detailText(el, "status"),
+		}
+		return
+	case el.hasClass("media_game"):
+		msg.GameTitle = title
+		msg.GameDescription = detailText(el, "description")
+		return
+	default:
+		return
+	}
+
+	msg.File = href
+}
+
+// coordinatesPattern extracts "lat,lon" from map links.
+var coordinatesPattern = regexp.MustCompile(`[?&]q=(-?[\d.]+),(-?[\d.]+)`)
+
+// parseLocation fills location or venue fields from a map link block.
+func parseLocation(el *node, title string, msg *parser.Message) {
+	m := coordinatesPattern.FindStringSubmatch(el.attrs["href"])
+	if m == nil {
+		return
+	}
+	lat, _ := strconv.ParseFloat(m[1], 64)
+	lon, _ := strconv.ParseFloat(m[2], 64)
+	msg.LocationInformation = &parser.LocationInformation{Latitude: lat, Longitude: lon}
+
+	if el.hasClass("media_venue") {
+		msg.PlaceName = title
+		msg.Address = detailText(el, "description")
+	}
+}
+
+// parsePoll reads a media_poll block.
+func parsePoll(el *node) *parser.Poll {
+	poll := &parser.Poll{}
+	for _, c := range el.children {
+		switch {
+		case c.tag == "":
+		case c.hasClass("question"):
+			poll.Question = strings.TrimSpace(c.textContent())
+		case c.hasClass("total"):
+			poll.TotalVoters = leadingNumber(c.textContent())
+		case c.hasClass("answer"):
+			answer := parser.PollAnswer{Text: strings.TrimPrefix(ownText(c), "- ")}
+			if votes := c.find(withClass("span", "details")); votes != nil {
+				answer.Voters = leadingNumber(votes.textContent())
+				answer.Chosen = strings.Contains(votes.textContent(), "chosen")
+			}
+			poll.Answers = append(poll.Answers, answer)
+		case c.hasClass("details"):
+			poll.Closed = strings.Contains(strings.ToLower(c.textContent()), "closed")
+		}
+	}
+	return poll
+}
+
+// detailText returns the trimmed text of the first descendant div with the class.
+func detailText(el *node, class string) string {
+	if d := el.find(withClass("div", class)); d != nil {
+		return strings.TrimSpace(d.textContent())
+	}
+	return ""
+}
+
+// leadingNumber parses the number at the start of text like "5 votes".
+func leadingNumber(text string) int {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fields[0])
+	return n
+}
+
+// statusDuration reads the duration from a status line like "00:42, 68.3 KB".
+func statusDuration(el *node) int {
+	status := el.find(withClass("div", "status"))
+	if status == nil {
+		return 0
+	}
+	field, _, _ := strings.Cut(strings.TrimSpace(status.textContent()), ",")
+	return parseDuration(field)
+}
+
+// parseDuration converts "mm:ss" or "hh:mm:ss" to seconds.
+// Returns 0 when the text is not a duration.
+func parseDuration(text string) int {
+	seconds := 0
+	for _, part := range strings.Split(strings.TrimSpace(text), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// convertEntities flattens formatted HTML into text entities.
+// Nested formatting takes the innermost entity type.
+func convertEntities(n *node, entityType string) []parser.TextEntity {
+	var entities []parser.TextEntity
+	add := func(e parser.TextEntity) {
+		if e.Text == "" {
+			return
+		}
+		if last := len(entities) - 1; last >= 0 && entities[last].Type == e.Type &&
+			entities[last].Href == "" && e.Href == "" {
+			entities[last].Text += e.Text
+			return
+		}
+		entities = append(entities, e)
+	}
+
+	for _, c := range n.children {
+		switch {
+		case c.tag == "":
+			add(parser.TextEntity{Type: orPlain(entityType), Text: c.text})
+		case c.tag == "br":
+			add(parser.TextEntity{Type: orPlain(entityType), Text: "\n"})
+		case c.tag == "a":
+			add(linkEntity(c))
+		default:
+			for _, e := range convertEntities(c, elementType(c, entityType)) {
+				add(e)
+			}
+		}
+	}
+
+	return entities
+}
+
+// elementType maps a formatting element to a Telegram entity type.
+func elementType(n *node, inherited string) string {
+	switch n.tag {
+	case "strong", "b":
+		return "bold"
+	case "em", "i":
+		return "italic"
+	case "code":
+		return "code"
+	case "pre":
+		return "pre"
+	case "u":
+		return "underline"
+	case "s", "strike", "del":
+		return "strikethrough"
+	case "blockquote":
+		return "blockquote"
+	case "span":
+		if n.hasClass("spoiler") {
+			return "spoiler"
+		}
+	}
+	return inherited
+}
+
+// linkEntity maps an anchor to a link-like entity.
+func linkEntity(a *node) parser.TextEntity {
+	text := a.textContent()
+	href := a.attrs["href"]
+	onclick := a.attrs["onclick"]
+
+	switch {
+	case strings.Contains(onclick, "ShowHashtag"):
+		return parser.TextEntity{Type: "hashtag", Text: text}
+	case strings.Contains(onclick, "ShowCashtag"):
+		return parser.TextEntity{Type: "cashtag", Text: text}
+	case strings.Contains(onclick, "ShowBotCommand"):
+		return parser.TextEntity{Type: "bot_command", Text: text}
+	case strings.Contains(onclick, "ShowMentionName"):
+		return parser.TextEntity{Type: "mention_name", Text: text}
+	case strings.HasPrefix(href, "mailto:"):
+		return parser.TextEntity{Type: "email", Text: text}
+	case strings.HasPrefix(href, "tel:"):
+		return parser.TextEntity{Type: "phone", Text: text}
+	case strings.HasPrefix(text, "@"):
+		return parser.TextEntity{Type: "mention", Text: text}
+	case href == "" || href == text:
+		return parser.TextEntity{Type: "link", Text: text}
+	}
+	return parser.TextEntity{Type: "text_link", Text: text, Href: href}
+}
+
+// trimEntities removes the indentation whitespace that surrounds message
+// text in the exported markup.
+func trimEntities(entities []parser.TextEntity) []parser.TextEntity {
+	for len(entities) > 0 {
+		entities[0].Text = strings.TrimLeft(entities[0].Text, " \t\r\n")
+		if entities[0].Text != "" {
+			break
+		}
+		entities = entities[1:]
+	}
+	for len(entities) > 0 {
+		last := len(entities) - 1
+		entities[last].Text = strings.TrimRight(entities[last].Text, " \t\r\n")
+		if entities[last].Text != "" {
+			break
+		}
+		entities = entities[:last]
+	}
+	return entities
+}
+
+// parseDateTitle converts a date title such as
+// "15.01.2024 14:30:00 UTC+03:00" to the JSON export format.
+func parseDateTitle(title string) (string, error) {
+	fields := strings.Fields(title)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid date %q", title)
+	}
+	t, err := time.Parse("02.01.2006 15:04:05", fields[0]+" "+fields[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q: %w", title, err)
+	}
+	return t.Format("2006-01-02T15:04:05"), nil
+}
+
+// ownText returns the element's text without nested elements,
+// e.g. the author name without the forwarded date span.
+func ownText(n *node) string {
+	var builder strings.Builder
+	for _, c := range n.children {
+		if c.tag == "" {
+			builder.WriteString(c.text)
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// findMessages returns all message elements of a page in document order.
+func findMessages(root *node) []*node {
+	var messages []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			if c.tag == "" {
+				continue
+			}
+			if c.tag == "div" && c.hasClass("message") && strings.HasPrefix(c.attrs["id"], "message") {
+				messages = append(messages, c)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return messages
+}
+
+func orPlain(entityType string) string {
+	if entityType == "" {
+		return "plain"
+	}
+	return entityType
+}
+
+// findPages lists messages*.html pages in numeric order.
+func findPages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read export directory: %w", err)
+	}
+
+	type page struct {
+		path  string
+		index int
+	}
+	var pages []page
+	for _, entry := range entries {
+		m := pagePattern.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() {
+			continue
+		}
+		index := 1
+		if m[1] != "" {
+			index, _ = strconv.Atoi(m[1])
+		}
+		pages = append(pages, page{filepath.Join(dir, entry.Name()), index})
+	}
+
+	sort.Slice(pages, func(i, j int) bool { return pages[i].index < pages[j].index })
+
+	paths := make([]string, len(pages))
+	for i, p := range pages {
+		paths[i] = p.path
+	}
+	return paths, nil
+}
+
+// readPage reads and parses a single export page.
+func readPage(path string) (*node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read page: %w", err)
+	}
+	return parseHTML(string(data)), nil
+}
@@ -0,0 +1,342 @@
+package htmlparser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+const sampleExport = "../../testdata/html_export"
+
+func collectMessages(t *testing.T, p *Parser) []*parser.Message {
+	t.Helper()
+
+	var messages []*parser.Message
+	for result := range p.StreamMessages() {
+		if result.Error != nil {
+			t.Errorf("Unexpected error: %v", result.Error)
+			continue
+		}
+		messages = append(messages, result.Message)
+	}
+	return messages
+}
+
+func TestParser_GetChatInfo(t *testing.T) {
+	p, err := New(sampleExport)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	name, chatType, err := p.GetChatInfo()
+	if err != nil {
+		t.Fatalf("GetChatInfo failed: %v", err)
+	}
+
+	if name != "Рабочий чат" {
+		t.Errorf("name = %q, want %q", name, "Рабочий чат")
+	}
+	if chatType != "" {
+		t.Errorf("chatType = %q, want empty", chatType)
+	}
+}
+
+func TestParser_StreamMessages_PagesInOrder(t *testing.T) {
+	p, err := New(filepath.Join(sampleExport, "messages.html"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	messages := collectMessages(t, p)
+
+	var ids []int64
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+
+	want := []int64{1, 2, 3, 4, 5, 6, 7}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestParser_StreamMessages_Fields(t *testing.T) {
+	p, err := New(sampleExport)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	messages := collectMessages(t, p)
+	if len(messages) != 7 {
+		t.Fatalf("Expected 7 messages, got %d", len(messages))
+	}
+
+	first := messages[0]
+	if first.From != "Иван" {
+		t.Errorf("From = %q, want %q", first.From, "Иван")
+	}
+	if first.Date != "2024-01-15T14:30:00" {
+		t.Errorf("Date = %q, want %q", first.Date, "2024-01-15T14:30:00")
+	}
+	if len(first.Text.Entities) != 1 || first.Text.Entities[0].Text != "Привет, как дела?" {
+		t.Errorf("Entities = %+v, want single plain entity", first.Text.Entities)
+	}
+
+	// Joined message inherits the author
+	joined := messages[1]
+	if joined.From != "Иван" {
+		t.Errorf("joined From = %q, want %q", joined.From, "Иван")
+	}
+	wantEntities := []parser.TextEntity{
+		{Type: "plain", Text: "Это "},
+		{Type: "bold", Text: "важный"},
+		{Type: "plain", Text: " текст с "},
+		{Type: "text_link", Text: "ссылкой", Href: "https://example.com"},
+		{Type: "plain", Text: "\nи \"кавычками\""},
+	}
+	if len(joined.Text.Entities) != len(wantEntities) {
+		t.Fatalf("Entities = %+v, want %+v", joined.Text.Entities, wantEntities)
+	}
+	for i := range wantEntities {
+		if joined.Text.Entities[i] != wantEntities[i] {
+			t.Errorf("entity %d = %+v, want %+v", i, joined.Text.Entities[i], wantEntities[i])
+		}
+	}
+
+	// Service message takes the previous date
+	service := messages[2]
+	if service.Type != "service" {
+		t.Errorf("Type = %q, want %q", service.Type, "service")
+	}
+	if service.Text.Plain != "Иван invited Мария" {
+		t.Errorf("Text = %q, want %q", service.Text.Plain, "Иван invited Мария")
+	}
+	if service.Date != "2024-01-15T14:30:30" {
+		t.Errorf("Date = %q, want %q", service.Date, "2024-01-15T14:30:30")
+	}
+
+	reply := messages[3]
+	if reply.ReplyToMsgID == nil || *reply.ReplyToMsgID != 1 {
+		t.Errorf("ReplyToMsgID = %v, want 1", reply.ReplyToMsgID)
+	}
+
+	forwarded := messages[4]
+	if forwarded.ForwardedFrom != "Алексей" {
+		t.Errorf("ForwardedFrom = %q, want %q", forwarded.ForwardedFrom, "Алексей")
+	}
+	if forwarded.ForwardedDate != "2023-11-02T09:00:00" {
+		t.Errorf("ForwardedDate = %q, want %q", forwarded.ForwardedDate, "2023-11-02T09:00:00")
+	}
+	if forwarded.From != "Пётр" {
+		t.Errorf("From = %q, want %q", forwarded.From, "Пётр")
+	}
+	if len(forwarded.Text.Entities) != 1 || forwarded.Text.Entities[0].Text != "Важная информация" {
+		t.Errorf("Entities = %+v, want forwarded text", forwarded.Text.Entities)
+	}
+}
+
+func TestParser_StreamMessages_Media(t *testing.T) {
+	p, err := New(sampleExport)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	messages := collectMessages(t, p)
+	if len(messages) != 7 {
+		t.Fatalf("Expected 7 messages, got %d", len(messages))
+	}
+
+	photo := messages[5]
+	if photo.Photo != "photos/photo_1@10-02-2024_10-02-00.jpg" {
+		t.Errorf("Photo = %q", photo.Photo)
+	}
+
+	voice := messages[6]
+	if voice.MediaType != "voice_message" {
+		t.Errorf("MediaType = %q, want %q", voice.MediaType, "voice_message")
+	}
+	if voice.File != "voice_messages/audio_1@10-02-2024_10-03-00.ogg" {
+		t.Errorf("File = %q", voice.File)
+	}
+	if voice.DurationSeconds != 42 {
+		t.Errorf("DurationSeconds = %d, want 42", voice.DurationSeconds)
+	}
+	if voice.From != "Анна" {
+		t.Errorf("From = %q, want %q", voice.From, "Анна")
+	}
+}
+
+func TestParser_StreamMessages_ServiceBeforeDate(t *testing.T) {
+	p, err := New("../../testdata/html_service_first")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	// A service message without a date is skipped with an error, the
+	// rest of the chat is still read
+	var dates []string
+	var errs []error
+	for result := range p.StreamMessages() {
+		if result.Error != nil {
+			errs = append(errs, result.Error)
+			continue
+		}
+		dates = append(dates, result.Message.Date)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("errors = %v, want one for message 1", errs)
+	}
+	want := []string{"2024-01-14T09:00:00", "2024-01-15T14:30:00"}
+	if len(dates) != len(want) {
+		t.Fatalf("dates = %v, want %v", dates, want)
+	}
+	for i := range want {
+		if dates[i] != want[i] {
+			t.Errorf("dates = %v, want %v", dates, want)
+			break
+		}
+	}
+}
+
+func TestIsExport(t *testing.T) {
+	tempDir := t.TempDir()
+	jsonFile := filepath.Join(tempDir, "result.json")
+	if err := os.WriteFile(jsonFile, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	if !IsExport(sampleExport) {
+		t.Errorf("IsExport(dir) = false, want true")
+	}
+	if !IsExport(filepath.Join(sampleExport, "messages2.html")) {
+		t.Errorf("IsExport(page) = false, want true")
+	}
+	if IsExport(jsonFile) {
+		t.Errorf("IsExport(json) = true, want false")
+	}
+	if IsExport(tempDir) {
+		t.Errorf("IsExport(empty dir) = true, want false")
+	}
+}
+
+func TestNew_NoPages_ReturnsError(t *testing.T) {
+	if _, err := New(t.TempDir()); err == nil {
+		t.Error("Expected error for directory without pages")
+	}
+}
+
+func TestParseDateTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"15.01.2024 14:30:00 UTC+03:00", "2024-01-15T14:30:00"},
+		{"01.12.2023 09:05:07", "2023-12-01T09:05:07"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDateTitle(tt.title)
+		if err != nil {
+			t.Errorf("parseDateTitle(%q) error: %v", tt.title, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDateTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	if _, err := parseDateTitle("yesterday"); err == nil {
+		t.Error("Expected error for invalid date")
+	}
+}
+
+func TestParseMedia_Structured(t *testing.T) {
+	root := parseHTML(`
+<div class="media_wrap clearfix" id="poll">
+ <div class="media_poll">
+  <div class="question bold">Куда идём?</div>
+  <div class="details">Closed poll</div>
+  <div class="answer">- Кино <span class="details">2 votes, chosen vote</span></div>
+  <div class="answer">- Театр <span class="details">1 vote</span></div>
+  <div class="total details">3 votes</div>
+ </div>
+</div>
+<div class="media_wrap clearfix" id="location">
+ <a class="media clearfix pull_left block_link media_location" href="https://maps.google.com/maps?q=55.751244,37.618423&amp;ll=55.751244,37.618423&amp;z=16">
+  <div class="body"><div class="title bold">Location</div></div>
+ </a>
+</div>
+<div class="media_wrap clearfix" id="contact">
+ <div class="media clearfix pull_left media_contact">
+  <div class="body"><div class="title bold">Мария</div><div class="status details">+79991234567</div></div>
+ </div>
+</div>`)
+
+	wrap := func(id string) *node {
+		return root.find(func(n *node) bool { return n.attrs["id"] == id })
+	}
+
+	var poll parser.Message
+	parseMedia(wrap("poll"), &poll)
+	if poll.Poll == nil {
+		t.Fatal("Poll not parsed")
+	}
+	if poll.Poll.Question != "Куда идём?" || !poll.Poll.Closed || poll.Poll.TotalVoters != 3 {
+		t.Errorf("Poll = %+v", poll.Poll)
+	}
+	wantAnswers := []parser.PollAnswer{{Text: "Кино", Voters: 2, Chosen: true}, {Text: "Театр", Voters: 1}}
+	if len(poll.Poll.Answers) != 2 || poll.Poll.Answers[0] != wantAnswers[0] || poll.Poll.Answers[1] != wantAnswers[1] {
+		t.Errorf("Answers = %+v, want %+v", poll.Poll.Answers, wantAnswers)
+	}
+
+	var location parser.Message
+	parseMedia(wrap("location"), &location)
+	if location.LocationInformation == nil || location.LocationInformation.Longitude != 37.618423 {
+		t.Errorf("LocationInformation = %+v", location.LocationInformation)
+	}
+	if location.File != "" {
+		t.Errorf("File = %q, want empty", location.File)
+	}
+
+	var contact parser.Message
+	parseMedia(wrap("contact"), &contact)
+	if contact.ContactInformation == nil || contact.ContactInformation.PhoneNumber != "+79991234567" {
+		t.Errorf("ContactInformation = %+v", contact.ContactInformation)
+	}
+}
+
+func TestParseReactions(t *testing.T) {
+	root := parseHTML(`<span class="reactions">
+ <span class="reaction"><span class="emoji">👍</span><span class="count">3</span></span>
+ <span class="reaction"><span class="emoji">🔥</span><span class="userpics"><div class="userpic"></div><div class="userpic"></div></span></span>
+</span>`)
+
+	el := root.find(func(n *node) bool { return n.hasClass("reactions") })
+	reactions := parseReactions(el)
+
+	want := []parser.Reaction{
+		{Type: "emoji", Emoji: "👍", Count: 3},
+		{Type: "emoji", Emoji: "🔥", Count: 2},
+	}
+	if len(reactions) != len(want) {
+		t.Fatalf("reactions = %+v, want %+v", reactions, want)
+	}
+	for i := range want {
+		if reactions[i].Emoji != want[i].Emoji || reactions[i].Count != want[i].Count || reactions[i].Type != want[i].Type {
+			t.Errorf("reaction %d = %+v, want %+v", i, reactions[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package locale
+
+import (
+	"fmt"
+	"time"
+)
+
+var en = &Locale{
+	name: "en",
+	messages: map[string]string{
+		// Message labels
+		"service":        "Service",
+		"reply":          "In reply to",
+		"forwarded":      "Forwarded from",
+		"saved_from":     "Saved from",
+		"edited":         "edited",
+		"unknown":        "Unknown",
+		"topic":          "Topic %d",
+		"thread":         "Thread from %s (messages: %d)",
+		"custom_emoji":   "emoji",
+		"sticker":        "Sticker",
+		"gif":            "GIF",
+		"voice":          "Voice message",
+		"video_message":  "Video message",
+		"video":          "Video",
+		"audio":          "Audio",
+		"photo":          "Photo",
+		"file":           "File",
+		"size.b":         "B",
+		"size.kb":        "KB",
+		"size.mb":        "MB",
+		"size.gb":        "GB",
+		"dice":           "Dice",
+		"game":           "Game",
+		"poll":           "Poll",
+		"poll.closed":    "closed",
+		"poll.total":     "Total",
+		"votes.one":      "vote",
+		"votes.other":    "votes",
+		"venue":          "Venue",
+		"live_location":  "Live location",
+		"location":       "Location",
+		"contact":        "Contact",
+		"days.one":       "day",
+		"days.other":     "days",
+		"hours.one":      "hour",
+		"hours.other":    "hours",
+		"minutes.one":    "minute",
+		"minutes.other":  "minutes",
+		"points.one":     "point",
+		"points.other":   "points",
+		"boosts.one":     "boost",
+		"boosts.other":   "boosts",
+		"messages.one":   "message",
+		"messages.other": "messages",
+		"files.one":      "file",
+		"files.other":    "files",
+		"chats.one":      "chat",
+		"chats.other":    "chats",
+
+		// Service actions
+		"action.create_group":          "%s created group “%s”",
+		"action.create_channel":        "Channel “%s” created",
+		"action.migrate_to_supergroup": "Group converted to a supergroup",
+		"action.migrate_from_group":    "Supergroup created from group “%s”",
+		"action.invite_members":        "%s added %s",
+		"action.remove_members":        "%s removed %s",
+		"action.joined":                "%s joined the group",
+		"action.left":                  "%s left the group",
+		"action.join_group_by_link":    "%s joined via an invite link from %s",
+		"action.join_group_by_request": "%s joined by request",
+		"action.edit_group_title":      "Title changed to “%s”",
+		"action.edit_group_photo":      "%s changed the group photo",
+		"action.delete_group_photo":    "%s removed the group photo",
+		"action.pin_message":           "%s pinned a message%s",
+		"action.phone_call":            "Call",
+		"action.group_call":            "Video chat",
+		"action.invite_to_group_call":  "%s invited %s to the video chat",
+		"action.clear_history":         "History cleared",
+		"action.topic_created":         "%s created topic “%s”",
+		"action.topic_edit":            "%s edited the topic",
+		"action.topic_renamed":         "%s renamed the topic to “%s”",
+		"action.set_messages_ttl":      "%s set messages to auto-delete after %s",
+		"action.messages_ttl_off":      "%s disabled message auto-delete",
+		"action.group_call_scheduled":  "%s scheduled a video chat for %s",
+		"action.score_in_game":         "%s scored %s in the game",
+		"action.edit_chat_theme":       "%s changed the chat theme to %s",
+		"action.chat_theme_off":        "%s disabled the chat theme",
+		"action.take_screenshot":       "%s took a screenshot",
+		"action.send_payment":          "%s paid %s",
+		"action.boost_apply":           "%s applied %s to the group",
+		"call.missed":                  "missed",
+		"call.busy":                    "declined",
+		"call.disconnect":              "disconnected",
+
+		// Console and log output
+		"console.loading":          "Loading: %s",
+		"console.indexing":         "Indexing messages for replies...",
+		"console.chats":            "Chats found: %d",
+		"console.messages":         "Messages found: %d",
+		"console.done":             "Done! Created %s, skipped %s",
+		"console.done_account":     "Done! Converted %s, created %s, skipped %s",
+		"console.unknown_entities": "Unknown formatting kept as text: %s",
+		"console.group":            "Chat: %s",
+		"console.period":           "Processed: %s (%s)",
+		"console.topic":            "Topic: %s (%s)",
+		"console.threads":          "Threads: %d (%s)",
+		"console.earlier":          "Written by earlier runs: %d",
+		"console.edited":           "File changed since the last run: %s",
+		"console.merge_input":      "%s: messages %d–%d (%s — %s)",
+		"console.merge":            "Exports merged: %d, duplicates: %d, replaced by newer edits: %d, conflicts: %d",
+		"console.merge_gap":        "Gap: no export covers messages between %d (%s) and %d (%s)",
+		"console.merge_conflict":   "Conflict in message %d: versions differ in %s, kept %s",
+	},
+	plural: func(n int) string {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+	months: [13]string{"",
+		"january", "february", "march", "april", "may", "june",
+		"july", "august", "september", "october", "november", "december",
+	},
+	monthsGenitive: [13]string{"",
+		"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December",
+	},
+	date: func(l *Locale, t time.Time) string {
+		return fmt.Sprintf("%s %d, %d", l.MonthGenitive(t.Month()), t.Day(), t.Year())
+	},
+}
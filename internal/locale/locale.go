@@ -0,0 +1,94 @@
+package locale
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Locale holds the message catalog, plural rules and month names of one
+// output language.
+type Locale struct {
+	name     string
+	messages map[string]string
+	// plural picks the plural form suffix for n, e.g. "one" or "many"
+	plural func(n int) string
+	// months are lowercase nominative names, genitive names are used in
+	// dates such as "15 января"
+	months         [13]string
+	monthsGenitive [13]string
+	// date formats a day with its month name
+	date func(l *Locale, t time.Time) string
+}
+
+// locales lists the shipped languages by name.
+var locales = map[string]*Locale{
+	ru.name: ru,
+	en.name: en,
+}
+
+// Default returns the Russian locale.
+func Default() *Locale {
+	return ru
+}
+
+// Get returns the locale with the given name.
+func Get(name string) (*Locale, error) {
+	if l, ok := locales[strings.ToLower(name)]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("unknown locale %q (want %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of the shipped locales, sorted.
+func Names() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Name returns the locale name, e.g. "ru".
+func (l *Locale) Name() string {
+	return l.name
+}
+
+// T returns the catalog message for key formatted with args. Keys missing
+// from the catalog fall back to Russian, then to the key itself.
+func (l *Locale) T(key string, args ...any) string {
+	format, ok := l.messages[key]
+	if !ok {
+		if format, ok = ru.messages[key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// N formats n with the plural form of the word under key, e.g. "3 голоса".
+// Catalogs hold plural forms as "key.one", "key.few", "key.many" or
+// "key.other".
+func (l *Locale) N(n int, key string) string {
+	return fmt.Sprintf("%d %s", n, l.T(key+"."+l.plural(n)))
+}
+
+// Month returns the lowercase nominative month name, e.g. "январь".
+func (l *Locale) Month(m time.Month) string {
+	return l.months[m]
+}
+
+// MonthGenitive returns the month name as used with a day, e.g. "января".
+func (l *Locale) MonthGenitive(m time.Month) string {
+	return l.monthsGenitive[m]
+}
+
+// Date formats a day with its month name, e.g. "15 января 2024".
+func (l *Locale) Date(t time.Time) string {
+	return l.date(l, t)
+}
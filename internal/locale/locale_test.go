@@ -0,0 +1,97 @@
+package locale
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	for _, name := range []string{"ru", "en", "EN"} {
+		if _, err := Get(name); err != nil {
+			t.Errorf("Get(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := Get("de"); err == nil {
+		t.Error("Get(\"de\") should fail")
+	}
+}
+
+func TestT(t *testing.T) {
+	en, _ := Get("en")
+	tests := []struct {
+		loc  *Locale
+		key  string
+		args []any
+		want string
+	}{
+		{ru, "reply", nil, "В ответ на"},
+		{en, "reply", nil, "In reply to"},
+		{ru, "topic", []any{7}, "Тема 7"},
+		{en, "topic", []any{7}, "Topic 7"},
+		{en, "missing", nil, "missing"},
+	}
+	for _, tt := range tests {
+		if got := tt.loc.T(tt.key, tt.args...); got != tt.want {
+			t.Errorf("%s.T(%q) = %q, want %q", tt.loc.Name(), tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestT_CatalogsComplete(t *testing.T) {
+	for _, l := range locales {
+		for key := range ru.messages {
+			if _, ok := l.messages[key]; !ok && !isPluralForm(key) {
+				t.Errorf("locale %s misses %q", l.name, key)
+			}
+		}
+	}
+}
+
+// isPluralForm reports whether key is a plural form, which differ
+// between languages.
+func isPluralForm(key string) bool {
+	for _, suffix := range []string{".one", ".few", ".many", ".other"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestN(t *testing.T) {
+	tests := map[int]string{
+		0: "0 голосов", 1: "1 голос", 2: "2 голоса", 4: "4 голоса", 5: "5 голосов",
+		11: "11 голосов", 14: "14 голосов", 21: "21 голос", 22: "22 голоса", 111: "111 голосов",
+	}
+	for n, want := range tests {
+		if got := ru.N(n, "votes"); got != want {
+			t.Errorf("ru.N(%d) = %q, want %q", n, got, want)
+		}
+	}
+
+	en, _ := Get("en")
+	for n, want := range map[int]string{0: "0 votes", 1: "1 vote", 2: "2 votes", 21: "21 votes"} {
+		if got := en.N(n, "votes"); got != want {
+			t.Errorf("en.N(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestMonths(t *testing.T) {
+	en, _ := Get("en")
+	day := time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC)
+
+	if got := ru.Month(day.Month()); got != "март" {
+		t.Errorf("ru.Month() = %q, want %q", got, "март")
+	}
+	if got := ru.Date(day); got != "8 марта 2024" {
+		t.Errorf("ru.Date() = %q, want %q", got, "8 марта 2024")
+	}
+	if got := en.Month(day.Month()); got != "march" {
+		t.Errorf("en.Month() = %q, want %q", got, "march")
+	}
+	if got := en.Date(day); got != "March 8, 2024" {
+		t.Errorf("en.Date() = %q, want %q", got, "March 8, 2024")
+	}
+}
@@ -0,0 +1,149 @@
+package locale
+
+import (
+	"fmt"
+	"time"
+)
+
+var ru = &Locale{
+	name: "ru",
+	messages: map[string]string{
+		// Message labels
+		"service":       "Служебное",
+		"reply":         "В ответ на",
+		"forwarded":     "Переслано от",
+		"saved_from":    "Сохранено из",
+		"edited":        "изм.",
+		"unknown":       "Unknown",
+		"topic":         "Тема %d",
+		"thread":        "Ветка от %s (сообщений: %d)",
+		"custom_emoji":  "эмодзи",
+		"sticker":       "Стикер",
+		"gif":           "GIF",
+		"voice":         "Голосовое",
+		"video_message": "Видеосообщение",
+		"video":         "Видео",
+		"audio":         "Аудио",
+		"photo":         "Фото",
+		"file":          "Файл",
+		"size.b":        "Б",
+		"size.kb":       "КБ",
+		"size.mb":       "МБ",
+		"size.gb":       "ГБ",
+		"dice":          "Кубик",
+		"game":          "Игра",
+		"poll":          "Опрос",
+		"poll.closed":   "закрыт",
+		"poll.total":    "Всего",
+		"votes.one":     "голос",
+		"votes.few":     "голоса",
+		"votes.many":    "голосов",
+		"venue":         "Место",
+		"live_location": "Трансляция геопозиции",
+		"location":      "Геопозиция",
+		"contact":       "Контакт",
+		"days.one":      "день",
+		"days.few":      "дня",
+		"days.many":     "дней",
+		"hours.one":     "час",
+		"hours.few":     "часа",
+		"hours.many":    "часов",
+		"minutes.one":   "минуту",
+		"minutes.few":   "минуты",
+		"minutes.many":  "минут",
+		"points.one":    "очко",
+		"points.few":    "очка",
+		"points.many":   "очков",
+		"boosts.one":    "буст",
+		"boosts.few":    "буста",
+		"boosts.many":   "бустов",
+		"messages.one":  "сообщение",
+		"messages.few":  "сообщения",
+		"messages.many": "сообщений",
+		"files.one":     "файл",
+		"files.few":     "файла",
+		"files.many":    "файлов",
+		"chats.one":     "чат",
+		"chats.few":     "чата",
+		"chats.many":    "чатов",
+
+		// Service actions. Exports do not record gender, so verbs use the
+		// "(а)" form
+		"action.create_group":          "%s создал(а) группу «%s»",
+		"action.create_channel":        "Канал «%s» создан",
+		"action.migrate_to_supergroup": "Группа преобразована в супергруппу",
+		"action.migrate_from_group":    "Супергруппа создана из группы «%s»",
+		"action.invite_members":        "%s добавил(а) в группу: %s",
+		"action.remove_members":        "%s удалил(а) из группы: %s",
+		"action.joined":                "%s вступил(а) в группу",
+		"action.left":                  "%s покинул(а) группу",
+		"action.join_group_by_link":    "%s вступил(а) по ссылке, автор ссылки: %s",
+		"action.join_group_by_request": "%s вступил(а) по заявке",
+		"action.edit_group_title":      "Название изменено на «%s»",
+		"action.edit_group_photo":      "%s изменил(а) фото группы",
+		"action.delete_group_photo":    "%s удалил(а) фото группы",
+		"action.pin_message":           "%s закрепил(а) сообщение%s",
+		"action.phone_call":            "Звонок",
+		"action.group_call":            "Видеочат",
+		"action.invite_to_group_call":  "%s пригласил(а) в видеочат: %s",
+		"action.clear_history":         "История очищена",
+		"action.topic_created":         "%s создал(а) тему «%s»",
+		"action.topic_edit":            "%s изменил(а) тему",
+		"action.topic_renamed":         "%s переименовал(а) тему в «%s»",
+		"action.set_messages_ttl":      "%s включил(а) автоудаление сообщений через %s",
+		"action.messages_ttl_off":      "%s отключил(а) автоудаление сообщений",
+		"action.group_call_scheduled":  "%s запланировал(а) видеочат на %s",
+		"action.score_in_game":         "%s набрал(а) в игре %s",
+		"action.edit_chat_theme":       "%s изменил(а) тему чата на %s",
+		"action.chat_theme_off":        "%s отключил(а) тему чата",
+		"action.take_screenshot":       "%s сделал(а) снимок экрана",
+		"action.send_payment":          "%s оплатил(а) %s",
+		"action.boost_apply":           "%s применил(а) к группе %s",
+		"call.missed":                  "пропущен",
+		"call.busy":                    "отклонён",
+		"call.disconnect":              "прерван",
+
+		// Console and log output
+		"console.loading":          "Загрузка: %s",
+		"console.indexing":         "Индексация сообщений для ответов...",
+		"console.chats":            "Найдено чатов: %d",
+		"console.messages":         "Найдено сообщений: %d",
+		"console.done":             "Готово! Создано %s, пропущено %s",
+		"console.done_account":     "Готово! Обработано %s, создано %s, пропущено %s",
+		"console.unknown_entities": "Неизвестные типы разметки оставлены текстом: %s",
+		"console.group":            "Группа: %s",
+		"console.period":           "Обработка: %s (%s)",
+		"console.topic":            "Тема: %s (%s)",
+		"console.threads":          "Веток: %d (%s)",
+		"console.earlier":          "Записано в прошлых запусках: %d",
+		"console.edited":           "Файл изменён после прошлого запуска: %s",
+		"console.merge_input":      "%s: сообщения %d–%d (%s — %s)",
+		"console.merge":            "Объединено экспортов: %d, повторов: %d, из них с более новой правкой: %d, конфликтов: %d",
+		"console.merge_gap":        "Пропуск: промежуток между сообщениями %d (%s) и %d (%s) не покрыт ни одним экспортом",
+		"console.merge_conflict":   "Конфликт в сообщении %d: версии различаются в %s, взята из %s",
+	},
+	plural: func(n int) string {
+		n %= 100
+		if n >= 11 && n <= 14 {
+			return "many"
+		}
+		switch n % 10 {
+		case 1:
+			return "one"
+		case 2, 3, 4:
+			return "few"
+		}
+		return "many"
+	},
+	months: [13]string{"",
+		"январь", "февраль", "март", "апрель", "май", "июнь",
+		"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь",
+	},
+	monthsGenitive: [13]string{"",
+		"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря",
+	},
+	date: func(l *Locale, t time.Time) string {
+		return fmt.Sprintf("%d %s %d", t.Day(), l.MonthGenitive(t.Month()), t.Year())
+	},
+}
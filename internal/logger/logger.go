@@ -38,6 +38,14 @@ func New(errorLogPath string) (*Logger, error) {
 	}, nil
 }
 
+// NewConsole creates a Logger that only prints to the console.
+// LogError calls are ignored.
+func NewConsole() *Logger {
+	return &Logger{
+		useColors: supportsColors(),
+	}
+}
+
 // supportsColors checks if the terminal supports ANSI colors.
 func supportsColors() bool {
 	return term.IsTerminal(int(os.Stdout.Fd()))
@@ -0,0 +1,240 @@
+package merge
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// Input is one export of the merged chat.
+type Input struct {
+	// Path names the export in the report
+	Path string
+	// Dir is the folder media paths of the export are relative to
+	Dir      string
+	Messages <-chan parser.ParseResult
+}
+
+// Coverage is the range of messages found in one export.
+type Coverage struct {
+	Path                string
+	First, Last         int64
+	FirstDate, LastDate string
+	Messages            int
+}
+
+// Gap is a range of message IDs between First and Last, exclusive, that
+// no export covers.
+type Gap struct {
+	First, Last         int64
+	FirstDate, LastDate string
+}
+
+// Conflict is a message that differs between exports without a newer
+// edit. Kept names the export whose version was used.
+type Conflict struct {
+	ID    int64
+	Paths []string
+	Kept  string
+}
+
+// Report describes a merge once its stream is drained.
+type Report struct {
+	Inputs []Coverage
+	// Duplicates counts messages found in more than one export, Edits
+	// those of them replaced by a newer edit
+	Duplicates int
+	Edits      int
+	Conflicts  []Conflict
+	Gaps       []Gap
+}
+
+// Merger combines exports of one chat into a single stream of messages
+// ordered by ID. Exports list messages by ID, so they are merged while
+// streaming without holding a chat in memory.
+type Merger struct {
+	inputs []Input
+	report Report
+}
+
+// New creates a Merger of inputs. Later inputs are taken as newer exports
+// when versions of a message cannot be told apart by their edit time.
+func New(inputs []Input) *Merger {
+	m := &Merger{inputs: inputs}
+	for _, in := range inputs {
+		m.report.Inputs = append(m.report.Inputs, Coverage{Path: in.Path})
+	}
+	return m
+}
+
+// head is the next message of an input.
+type head struct {
+	input int
+	msg   *parser.Message
+	done  bool
+}
+
+// Stream yields the messages of all inputs ordered by ID, each ID once in
+// its newest version. Results carry the export folder of their message.
+func (m *Merger) Stream() <-chan parser.ParseResult {
+	ch := make(chan parser.ParseResult, 100)
+
+	go func() {
+		defer close(ch)
+
+		heads := make([]*head, len(m.inputs))
+		for i := range m.inputs {
+			heads[i] = &head{input: i}
+			m.advance(heads[i], ch)
+		}
+
+		for {
+			var next []*head
+			for _, h := range heads {
+				switch {
+				case h.done:
+				case len(next) == 0 || h.msg.ID < next[0].msg.ID:
+					next = []*head{h}
+				case h.msg.ID == next[0].msg.ID:
+					next = append(next, h)
+				}
+			}
+			if len(next) == 0 {
+				break
+			}
+
+			kept := m.pick(next)
+			ch <- parser.ParseResult{Message: kept.msg, ExportDir: m.inputs[kept.input].Dir}
+			for _, h := range next {
+				m.advance(h, ch)
+			}
+		}
+		m.report.Gaps = gaps(m.report.Inputs)
+	}()
+
+	return ch
+}
+
+// Report returns what the merge found. It is complete once the stream is
+// drained.
+func (m *Merger) Report() Report {
+	return m.report
+}
+
+// advance reads the next message of an input into h. Errors are passed
+// on, and messages out of ID order are reported and dropped, since they
+// cannot be merged while streaming.
+func (m *Merger) advance(h *head, ch chan<- parser.ParseResult) {
+	in := m.inputs[h.input]
+	cov := &m.report.Inputs[h.input]
+	for result := range in.Messages {
+		if result.Error != nil {
+			ch <- parser.ParseResult{Error: fmt.Errorf("%s: %w", in.Path, result.Error)}
+			continue
+		}
+		msg := result.Message
+		if h.msg != nil && msg.ID <= h.msg.ID {
+			ch <- parser.ParseResult{Error: fmt.Errorf("%s: message %d follows %d, exports must be ordered by ID", in.Path, msg.ID, h.msg.ID)}
+			continue
+		}
+
+		if cov.Messages == 0 {
+			cov.First, cov.FirstDate = msg.ID, msg.Date
+		}
+		cov.Last, cov.LastDate = msg.ID, msg.Date
+		cov.Messages++
+		h.msg = msg
+		return
+	}
+	h.done = true
+}
+
+// pick chooses the version of a message found in several exports: the
+// newest edit, then the latest input. Versions that differ without a
+// newer edit are reported as a conflict.
+func (m *Merger) pick(versions []*head) *head {
+	if len(versions) == 1 {
+		return versions[0]
+	}
+	m.report.Duplicates++
+
+	kept := versions[0]
+	for _, v := range versions[1:] {
+		if editTime(v.msg) >= editTime(kept.msg) {
+			kept = v
+		}
+	}
+	if slices.ContainsFunc(versions, func(v *head) bool { return editTime(v.msg) < editTime(kept.msg) }) {
+		m.report.Edits++
+	}
+
+	conflict := Conflict{ID: kept.msg.ID, Kept: m.inputs[kept.input].Path}
+	content := contentOf(kept.msg)
+	for _, v := range versions {
+		if v != kept && editTime(v.msg) == editTime(kept.msg) && contentOf(v.msg) != content {
+			conflict.Paths = append(conflict.Paths, m.inputs[v.input].Path)
+		}
+	}
+	if len(conflict.Paths) > 0 {
+		conflict.Paths = append(conflict.Paths, conflict.Kept)
+		m.report.Conflicts = append(m.report.Conflicts, conflict)
+	}
+	return kept
+}
+
+// editTime returns the Unix time of the last edit of a message, 0 when it
+// was not edited.
+func editTime(msg *parser.Message) int64 {
+	if t, err := strconv.ParseInt(msg.EditedUnixtime, 10, 64); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", msg.Edited); err == nil {
+		return t.Unix()
+	}
+	return 0
+}
+
+// contentOf returns the comparable content of a message. Reactions are
+// left out: they change between exports without an edit.
+func contentOf(msg *parser.Message) string {
+	c := *msg
+	c.Reactions = nil
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// gaps returns the ID ranges between the exports that none of them
+// covers. IDs of deleted messages are missing in every export, so a gap
+// only means that no export spans it.
+func gaps(inputs []Coverage) []Gap {
+	var covered []Coverage
+	for _, c := range inputs {
+		if c.Messages > 0 {
+			covered = append(covered, c)
+		}
+	}
+	slices.SortFunc(covered, func(a, b Coverage) int {
+		return cmp.Compare(a.First, b.First)
+	})
+
+	var result []Gap
+	for i, c := range covered {
+		if i == 0 {
+			continue
+		}
+		end := covered[i-1]
+		if c.First > end.Last+1 {
+			result = append(result, Gap{First: end.Last, Last: c.First, FirstDate: end.LastDate, LastDate: c.FirstDate})
+		}
+		if c.Last < end.Last {
+			// Contained in the earlier range, which still ends the coverage
+			covered[i] = end
+		}
+	}
+	return result
+}
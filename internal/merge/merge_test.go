@@ -0,0 +1,140 @@
+package merge
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/grigoriizhovtun/tg2md/internal/parser"
+)
+
+// export streams messages as an input of the merge.
+func export(path string, messages ...parser.Message) Input {
+	ch := make(chan parser.ParseResult, len(messages))
+	for i := range messages {
+		ch <- parser.ParseResult{Message: &messages[i]}
+	}
+	close(ch)
+	return Input{Path: path, Dir: path + "_dir", Messages: ch}
+}
+
+func message(id int64, text string) parser.Message {
+	return parser.Message{ID: id, Type: "message", Date: "2024-01-15T14:30:00", Text: parser.TextContent{Plain: text}}
+}
+
+func edited(msg parser.Message, unixtime string) parser.Message {
+	msg.EditedUnixtime = unixtime
+	return msg
+}
+
+// drain collects the merged stream.
+func drain(t *testing.T, m *Merger) (ids []int64, texts map[int64]string, dirs map[int64]string) {
+	t.Helper()
+	texts, dirs = make(map[int64]string), make(map[int64]string)
+	for result := range m.Stream() {
+		if result.Error != nil {
+			t.Logf("stream error: %v", result.Error)
+			continue
+		}
+		ids = append(ids, result.Message.ID)
+		texts[result.Message.ID] = result.Message.Text.Plain
+		dirs[result.Message.ID] = result.ExportDir
+	}
+	return ids, texts, dirs
+}
+
+func TestMerger_DeduplicatesByID(t *testing.T) {
+	m := New([]Input{
+		export("old", message(1, "a"), message(2, "b"), message(3, "c")),
+		export("new", message(2, "b"), message(3, "c"), message(4, "d")),
+	})
+
+	ids, _, dirs := drain(t, m)
+	if want := []int64{1, 2, 3, 4}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if dirs[1] != "old_dir" || dirs[4] != "new_dir" {
+		t.Errorf("export dirs = %v", dirs)
+	}
+
+	report := m.Report()
+	if report.Duplicates != 2 || report.Edits != 0 || len(report.Conflicts) != 0 || len(report.Gaps) != 0 {
+		t.Errorf("Report() = %+v", report)
+	}
+	if c := report.Inputs[1]; c.First != 2 || c.Last != 4 || c.Messages != 3 {
+		t.Errorf("coverage = %+v", c)
+	}
+}
+
+func TestMerger_PrefersNewestEdit(t *testing.T) {
+	m := New([]Input{
+		export("a", edited(message(1, "second edit"), "200")),
+		export("b", edited(message(1, "first edit"), "100")),
+		export("c", message(1, "original")),
+	})
+
+	_, texts, _ := drain(t, m)
+	if texts[1] != "second edit" {
+		t.Errorf("text = %q, want the newest edit", texts[1])
+	}
+	if report := m.Report(); report.Edits != 1 || len(report.Conflicts) != 0 {
+		t.Errorf("Report() = %+v", report)
+	}
+}
+
+func TestMerger_ReportsConflicts(t *testing.T) {
+	reacted := message(1, "same")
+	reacted.Reactions = []parser.Reaction{{Type: "emoji", Count: 3, Emoji: "👍"}}
+
+	m := New([]Input{
+		export("a", message(1, "same"), message(2, "one")),
+		export("b", reacted, message(2, "other")),
+	})
+
+	_, texts, _ := drain(t, m)
+	if texts[2] != "other" {
+		t.Errorf("text = %q, want the version of the later export", texts[2])
+	}
+
+	// Reactions change without an edit and are no conflict
+	want := []Conflict{{ID: 2, Paths: []string{"a", "b"}, Kept: "b"}}
+	conflicts := m.Report().Conflicts
+	if len(conflicts) != 1 || conflicts[0].ID != want[0].ID || conflicts[0].Kept != want[0].Kept ||
+		!slices.Equal(conflicts[0].Paths, want[0].Paths) {
+		t.Errorf("Conflicts = %+v, want %+v", conflicts, want)
+	}
+}
+
+func TestMerger_ReportsGaps(t *testing.T) {
+	m := New([]Input{
+		export("late", message(20, "x"), message(30, "y")),
+		export("early", message(1, "a"), message(10, "b")),
+		export("inner", message(22, "z")),
+		export("empty"),
+	})
+
+	ids, _, _ := drain(t, m)
+	if want := []int64{1, 10, 20, 22, 30}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	gaps := m.Report().Gaps
+	if len(gaps) != 1 || gaps[0].First != 10 || gaps[0].Last != 20 {
+		t.Errorf("Gaps = %+v, want one between 10 and 20", gaps)
+	}
+}
+
+func TestMerger_DropsUnorderedMessages(t *testing.T) {
+	m := New([]Input{export("a", message(2, "b"), message(1, "a"), message(3, "c"))})
+
+	var ids []int64
+	errors := 0
+	for result := range m.Stream() {
+		if result.Error != nil {
+			errors++
+			continue
+		}
+		ids = append(ids, result.Message.ID)
+	}
+	if want := []int64{2, 3}; !slices.Equal(ids, want) || errors != 1 {
+		t.Errorf("ids = %v with %d errors, want %v with 1", ids, errors, want)
+	}
+}
@@ -2,17 +2,29 @@ package parser
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
+// errClosed stops a stream whose parser was closed before the consumer
+// read it to the end.
+var errClosed = errors.New("parser closed")
+
 // Parser handles streaming JSON parsing of Telegram exports.
 type Parser struct {
 	decoder  *json.Decoder
 	file     *os.File
 	chatName string
 	chatType string
+	chatID   int64
+
+	// done is closed by Close to stop streams nobody reads anymore,
+	// streams waits for their goroutines
+	done    chan struct{}
+	streams sync.WaitGroup
 }
 
 // New creates a new Parser for the given file path.
@@ -25,11 +37,12 @@ func New(filePath string) (*Parser, error) {
 	return &Parser{
 		decoder: json.NewDecoder(file),
 		file:    file,
+		done:    make(chan struct{}),
 	}, nil
 }
 
-// GetChatInfo extracts chat name and type from the JSON.
-// Must be called before StreamMessages.
+// GetChatInfo extracts chat name and type from the JSON, and the chat ID
+// returned by ChatID. Must be called before StreamMessages.
 func (p *Parser) GetChatInfo() (name, chatType string, err error) {
 	// Reset to beginning of file
 	if _, err := p.file.Seek(0, 0); err != nil {
@@ -37,8 +50,9 @@ func (p *Parser) GetChatInfo() (name, chatType string, err error) {
 	}
 	p.decoder = json.NewDecoder(p.file)
 
-	// Navigate to find name and type fields
+	// Navigate to find name, type and id fields, which precede messages
 	depth := 0
+scan:
 	for {
 		token, err := p.decoder.Token()
 		if err == io.EOF {
@@ -76,12 +90,18 @@ func (p *Parser) GetChatInfo() (name, chatType string, err error) {
 						return "", "", fmt.Errorf("decode type: %w", err)
 					}
 					p.chatType = val
+				case "id":
+					if err := p.decoder.Decode(&p.chatID); err != nil {
+						return "", "", fmt.Errorf("decode id: %w", err)
+					}
+				case "messages":
+					break scan
 				}
 			}
 		}
 
-		// Stop once we have both
-		if p.chatName != "" && p.chatType != "" {
+		// Stop once we have all three
+		if p.chatName != "" && p.chatType != "" && p.chatID != 0 {
 			break
 		}
 	}
@@ -93,17 +113,25 @@ func (p *Parser) GetChatInfo() (name, chatType string, err error) {
 	return p.chatName, p.chatType, nil
 }
 
+// ChatID returns the chat ID read by GetChatInfo, 0 when the export has
+// none.
+func (p *Parser) ChatID() int64 {
+	return p.chatID
+}
+
 // StreamMessages returns a channel that yields messages one by one.
 // This enables memory-efficient processing of large files.
 func (p *Parser) StreamMessages() <-chan ParseResult {
 	ch := make(chan ParseResult, 100)
 
+	p.streams.Add(1)
 	go func() {
+		defer p.streams.Done()
 		defer close(ch)
 
 		// Reset to beginning
 		if _, err := p.file.Seek(0, 0); err != nil {
-			ch <- ParseResult{Error: fmt.Errorf("seek file: %w", err)}
+			send(p.done, ch, ParseResult{Error: fmt.Errorf("seek file: %w", err)})
 			return
 		}
 		p.decoder = json.NewDecoder(p.file)
@@ -117,7 +145,7 @@ func (p *Parser) StreamMessages() <-chan ParseResult {
 				return
 			}
 			if err != nil {
-				ch <- ParseResult{Error: fmt.Errorf("read token: %w", err)}
+				send(p.done, ch, ParseResult{Error: fmt.Errorf("read token: %w", err)})
 				return
 			}
 
@@ -135,7 +163,7 @@ func (p *Parser) StreamMessages() <-chan ParseResult {
 					}
 					// Skip other arrays
 					if err := skipArray(p.decoder); err != nil {
-						ch <- ParseResult{Error: err}
+						send(p.done, ch, ParseResult{Error: err})
 						return
 					}
 				}
@@ -148,21 +176,240 @@ func (p *Parser) StreamMessages() <-chan ParseResult {
 
 	streamMessages:
 		// Stream individual messages
+		p.streamArray(ch)
+	}()
+
+	return ch
+}
+
+// IsAccountExport reports whether the file is a full-account export,
+// where chats are nested under "chats.list" instead of the top level.
+func (p *Parser) IsAccountExport() (bool, error) {
+	if _, err := p.file.Seek(0, 0); err != nil {
+		return false, fmt.Errorf("seek file: %w", err)
+	}
+	p.decoder = json.NewDecoder(p.file)
+
+	if err := expectDelim(p.decoder, '{'); err != nil {
+		return false, err
+	}
+
+	for p.decoder.More() {
+		key, err := readKey(p.decoder)
+		if err != nil {
+			return false, err
+		}
+		switch key {
+		case "chats", "left_chats":
+			return true, nil
+		case "messages":
+			return false, nil
+		}
+		if err := skipValue(p.decoder); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// StreamChats returns a channel that yields the chats of a full-account
+// export one by one, each with its own message stream.
+// Chats are read from both "chats" and "left_chats" sections.
+func (p *Parser) StreamChats() <-chan ChatStream {
+	ch := make(chan ChatStream)
+
+	p.streams.Add(1)
+	go func() {
+		defer p.streams.Done()
+		defer close(ch)
+
+		if _, err := p.file.Seek(0, 0); err != nil {
+			send(p.done, ch, ChatStream{Error: fmt.Errorf("seek file: %w", err)})
+			return
+		}
+		p.decoder = json.NewDecoder(p.file)
+
+		if err := expectDelim(p.decoder, '{'); err != nil {
+			send(p.done, ch, ChatStream{Error: err})
+			return
+		}
+
 		for p.decoder.More() {
-			var msg Message
-			if err := p.decoder.Decode(&msg); err != nil {
-				ch <- ParseResult{Error: fmt.Errorf("decode message: %w", err)}
+			key, err := readKey(p.decoder)
+			if err != nil {
+				send(p.done, ch, ChatStream{Error: err})
+				return
+			}
+
+			if key != "chats" && key != "left_chats" {
+				if err := skipValue(p.decoder); err != nil {
+					send(p.done, ch, ChatStream{Error: err})
+					return
+				}
 				continue
 			}
-			ch <- ParseResult{Message: &msg}
+
+			if err := p.streamChatList(ch); err != nil {
+				if err != errClosed {
+					send(p.done, ch, ChatStream{Error: fmt.Errorf("%s: %w", key, err)})
+				}
+				return
+			}
 		}
 	}()
 
 	return ch
 }
 
-// Close closes the underlying file.
+// streamChatList walks a {"about": ..., "list": [...]} section and emits
+// every chat of its list.
+func (p *Parser) streamChatList(ch chan<- ChatStream) error {
+	if err := expectDelim(p.decoder, '{'); err != nil {
+		return err
+	}
+
+	for p.decoder.More() {
+		key, err := readKey(p.decoder)
+		if err != nil {
+			return err
+		}
+
+		if key != "list" {
+			if err := skipValue(p.decoder); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(p.decoder, '['); err != nil {
+			return err
+		}
+		for p.decoder.More() {
+			if err := p.streamChat(ch); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(p.decoder, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(p.decoder, '}')
+}
+
+// streamChat reads one chat object, emitting it as soon as its
+// "messages" array is reached. Header fields that follow the array
+// are not available to the consumer.
+func (p *Parser) streamChat(ch chan<- ChatStream) error {
+	if err := expectDelim(p.decoder, '{'); err != nil {
+		return err
+	}
+
+	var info ChatInfo
+	emitted := false
+
+	for p.decoder.More() {
+		key, err := readKey(p.decoder)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "name":
+			// Deleted accounts and saved messages have a null name
+			var val *string
+			if err := p.decoder.Decode(&val); err != nil {
+				return fmt.Errorf("decode name: %w", err)
+			}
+			if val != nil {
+				info.Name = *val
+			}
+		case "type":
+			if err := p.decoder.Decode(&info.Type); err != nil {
+				return fmt.Errorf("decode type: %w", err)
+			}
+		case "id":
+			if err := p.decoder.Decode(&info.ID); err != nil {
+				return fmt.Errorf("decode id: %w", err)
+			}
+		case "messages":
+			if err := expectDelim(p.decoder, '['); err != nil {
+				return err
+			}
+			messages := make(chan ParseResult, 100)
+			if !send(p.done, ch, ChatStream{Info: info, Messages: messages}) {
+				return errClosed
+			}
+			emitted = true
+
+			err := p.streamArray(messages)
+			close(messages)
+			if err != nil {
+				return err
+			}
+
+			if err := expectDelim(p.decoder, ']'); err != nil {
+				return err
+			}
+		default:
+			if err := skipValue(p.decoder); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !emitted {
+		messages := make(chan ParseResult)
+		close(messages)
+		if !send(p.done, ch, ChatStream{Info: info, Messages: messages}) {
+			return errClosed
+		}
+	}
+
+	return expectDelim(p.decoder, '}')
+}
+
+// streamArray decodes array elements as messages until the end of the
+// array. Returns errClosed when the parser is closed first.
+func (p *Parser) streamArray(ch chan<- ParseResult) error {
+	for p.decoder.More() {
+		result := ParseResult{}
+		var msg Message
+		if err := p.decoder.Decode(&msg); err != nil {
+			result.Error = fmt.Errorf("decode message: %w", err)
+		} else {
+			result.Message = &msg
+		}
+		if !send(p.done, ch, result) {
+			return errClosed
+		}
+	}
+	return nil
+}
+
+// send delivers v unless done is closed first, so a stream stops when its
+// consumer gives up. Reports whether v was delivered.
+func send[T any](done <-chan struct{}, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Close stops the streams that are still running and closes the
+// underlying file.
 func (p *Parser) Close() error {
+	if p.done != nil {
+		select {
+		case <-p.done:
+		default:
+			close(p.done)
+		}
+	}
+	p.streams.Wait()
 	if p.file != nil {
 		return p.file.Close()
 	}
@@ -189,3 +436,58 @@ func skipArray(decoder *json.Decoder) error {
 	}
 	return nil
 }
+
+// skipValue skips the next JSON value, including nested objects and arrays.
+func skipValue(decoder *json.Decoder) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("skip value: %w", err)
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok || delim == '}' || delim == ']' {
+		return nil
+	}
+
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("skip value: %w", err)
+		}
+		if d, ok := token.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// expectDelim reads the next token and checks it is the given delimiter.
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("read token: %w", err)
+	}
+	if d, ok := token.(json.Delim); !ok || d != want {
+		return fmt.Errorf("unexpected token %v, want %v", token, want)
+	}
+	return nil
+}
+
+// readKey reads an object key.
+func readKey(decoder *json.Decoder) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", fmt.Errorf("read token: %w", err)
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected token %v, want object key", token)
+	}
+	return key, nil
+}
@@ -2,9 +2,11 @@ package parser
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestTextContent_UnmarshalString(t *testing.T) {
@@ -47,6 +49,22 @@ func TestTextContent_UnmarshalArray(t *testing.T) {
 	}
 }
 
+func TestTextContent_UnmarshalPreLanguage(t *testing.T) {
+	input := `[{"type": "pre", "text": "x := 1", "language": "go"}]`
+
+	var tc TextContent
+	if err := json.Unmarshal([]byte(input), &tc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(tc.Entities) != 1 {
+		t.Fatalf("Entities length = %d, want 1", len(tc.Entities))
+	}
+	if tc.Entities[0].Language != "go" {
+		t.Errorf("Language = %q, want %q", tc.Entities[0].Language, "go")
+	}
+}
+
 func TestTextContent_UnmarshalEmptyString(t *testing.T) {
 	input := `""`
 
@@ -108,6 +126,9 @@ func TestParser_GetChatInfo(t *testing.T) {
 	if chatType != "private_supergroup" {
 		t.Errorf("chatType = %q, want %q", chatType, "private_supergroup")
 	}
+	if p.ChatID() != 1234567890 {
+		t.Errorf("ChatID() = %d, want %d", p.ChatID(), 1234567890)
+	}
 }
 
 func TestParser_StreamMessages_Basic(t *testing.T) {
@@ -299,3 +320,309 @@ func TestParser_FileNotFound(t *testing.T) {
 		t.Error("Expected error for nonexistent file")
 	}
 }
+
+func TestParser_IsAccountExport(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{
+			name: "single chat",
+			data: `{"name": "Test Chat", "type": "private_group", "id": 1, "messages": []}`,
+			want: false,
+		},
+		{
+			name: "account export",
+			data: `{"about": "...", "personal_information": {"first_name": "Иван"}, "chats": {"about": "...", "list": []}}`,
+			want: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempFile := filepath.Join(tempDir, fmt.Sprintf("test%d.json", i))
+			if err := os.WriteFile(tempFile, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			p, err := New(tempFile)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			defer p.Close()
+
+			got, err := p.IsAccountExport()
+			if err != nil {
+				t.Fatalf("IsAccountExport failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsAccountExport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_StreamChats(t *testing.T) {
+	tempDir := t.TempDir()
+	tempFile := filepath.Join(tempDir, "result.json")
+
+	testData := `{
+		"about": "Here is the data you requested.",
+		"personal_information": {"user_id": 1, "first_name": "Иван"},
+		"contacts": {"about": "...", "list": [{"first_name": "Мария", "phone_number": "+7"}]},
+		"chats": {
+			"about": "This page lists all chats from this export.",
+			"list": [
+				{
+					"name": "Рабочий чат",
+					"type": "private_supergroup",
+					"id": 100,
+					"messages": [
+						{"id": 1, "type": "message", "date": "2024-01-15T14:30:00", "from": "Иван", "text": "Привет!"},
+						{"id": 2, "type": "message", "date": "2024-01-15T14:31:00", "from": "Мария", "text": "Привет!"}
+					]
+				},
+				{
+					"type": "saved_messages",
+					"id": 200,
+					"messages": [
+						{"id": 10, "type": "message", "date": "2024-02-01T10:00:00", "from": "Иван", "text": "Заметка"}
+					]
+				}
+			]
+		},
+		"left_chats": {
+			"about": "...",
+			"list": [
+				{"name": "Старый чат", "type": "private_group", "id": 300, "messages": []}
+			]
+		}
+	}`
+
+	if err := os.WriteFile(tempFile, []byte(testData), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	p, err := New(tempFile)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer p.Close()
+
+	var infos []ChatInfo
+	var counts []int
+	for chat := range p.StreamChats() {
+		if chat.Error != nil {
+			t.Fatalf("Unexpected error: %v", chat.Error)
+		}
+		count := 0
+		for result := range chat.Messages {
+			if result.Error != nil {
+				t.Errorf("Unexpected error: %v", result.Error)
+				continue
+			}
+			count++
+		}
+		infos = append(infos, chat.Info)
+		counts = append(counts, count)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("Expected 3 chats, got %d", len(infos))
+	}
+
+	want := []ChatInfo{
+		{Name: "Рабочий чат", Type: "private_supergroup", ID: 100},
+		{Name: "", Type: "saved_messages", ID: 200},
+		{Name: "Старый чат", Type: "private_group", ID: 300},
+	}
+	wantCounts := []int{2, 1, 0}
+	for i := range want {
+		if infos[i] != want[i] {
+			t.Errorf("chat %d info = %+v, want %+v", i, infos[i], want[i])
+		}
+		if counts[i] != wantCounts[i] {
+			t.Errorf("chat %d messages = %d, want %d", i, counts[i], wantCounts[i])
+		}
+	}
+}
+
+func TestParser_CloseStopsStreams(t *testing.T) {
+	tempDir := t.TempDir()
+	tempFile := filepath.Join(tempDir, "result.json")
+
+	testData := `{"chats": {"list": [
+		{"name": "Первый", "type": "private_group", "id": 1, "messages": [
+			{"id": 1, "type": "message", "date": "2024-01-15T14:30:00", "from": "Иван", "text": "раз"},
+			{"id": 2, "type": "message", "date": "2024-01-15T14:31:00", "from": "Иван", "text": "два"}
+		]},
+		{"name": "Второй", "type": "private_group", "id": 2, "messages": []}
+	]}}`
+	if err := os.WriteFile(tempFile, []byte(testData), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	p, err := New(tempFile)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// The consumer gives up after the first chat, as on a conversion error
+	chats := p.StreamChats()
+	if chat := <-chats; chat.Error != nil {
+		t.Fatalf("Unexpected error: %v", chat.Error)
+	}
+
+	p.Close()
+	select {
+	case chat, ok := <-chats:
+		if ok {
+			t.Errorf("got chat %q after Close, want the stream stopped", chat.Info.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat stream still running after Close")
+	}
+}
+
+func TestMessage_UnmarshalMedia(t *testing.T) {
+	input := `{
+		"id": 7,
+		"type": "message",
+		"date": "2024-01-15T14:30:00",
+		"from": "Иван",
+		"file": "voice_messages/audio_1@15-01-2024_14-30-00.ogg",
+		"file_size": 68301,
+		"media_type": "voice_message",
+		"mime_type": "audio/ogg",
+		"duration_seconds": 42,
+		"text": ""
+	}`
+
+	var msg Message
+	if err := json.Unmarshal([]byte(input), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if msg.File != "voice_messages/audio_1@15-01-2024_14-30-00.ogg" {
+		t.Errorf("File = %q", msg.File)
+	}
+	if msg.MediaType != "voice_message" {
+		t.Errorf("MediaType = %q, want %q", msg.MediaType, "voice_message")
+	}
+	if msg.MimeType != "audio/ogg" {
+		t.Errorf("MimeType = %q, want %q", msg.MimeType, "audio/ogg")
+	}
+	if msg.DurationSeconds != 42 {
+		t.Errorf("DurationSeconds = %d, want 42", msg.DurationSeconds)
+	}
+	if msg.FileSize != 68301 {
+		t.Errorf("FileSize = %d, want 68301", msg.FileSize)
+	}
+}
+
+func TestMessage_UnmarshalStructured(t *testing.T) {
+	input := `{
+		"id": 8,
+		"type": "message",
+		"date": "2024-01-15T14:30:00",
+		"from": "Иван",
+		"poll": {
+			"question": "Куда идём?",
+			"closed": true,
+			"total_voters": 3,
+			"answers": [
+				{"text": "Кино", "voters": 2, "chosen": true},
+				{"text": "Театр", "voters": 1, "chosen": false}
+			]
+		},
+		"location_information": {"latitude": 55.751244, "longitude": 37.618423},
+		"contact_information": {"first_name": "Мария", "last_name": "", "phone_number": "+79991234567"},
+		"text": ""
+	}`
+
+	var msg Message
+	if err := json.Unmarshal([]byte(input), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if msg.Poll == nil || msg.Poll.Question != "Куда идём?" || !msg.Poll.Closed {
+		t.Fatalf("Poll = %+v", msg.Poll)
+	}
+	if len(msg.Poll.Answers) != 2 || !msg.Poll.Answers[0].Chosen || msg.Poll.Answers[1].Voters != 1 {
+		t.Errorf("Answers = %+v", msg.Poll.Answers)
+	}
+	if msg.LocationInformation == nil || msg.LocationInformation.Latitude != 55.751244 {
+		t.Errorf("LocationInformation = %+v", msg.LocationInformation)
+	}
+	if msg.ContactInformation == nil || msg.ContactInformation.PhoneNumber != "+79991234567" {
+		t.Errorf("ContactInformation = %+v", msg.ContactInformation)
+	}
+}
+
+func TestMessage_UnmarshalReactions(t *testing.T) {
+	input := `{
+		"id": 9,
+		"type": "message",
+		"date": "2024-01-15T14:30:00",
+		"edited": "2024-01-15T15:02:00",
+		"edited_unixtime": "1705320120",
+		"from": "Иван",
+		"text": "Привет",
+		"reactions": [
+			{"type": "emoji", "count": 2, "emoji": "👍", "recent": [{"from": "Мария", "from_id": "user456", "date": "2024-01-15T14:31:00"}]},
+			{"type": "custom_emoji", "count": 1, "document_id": "5368324170671202286"}
+		]
+	}`
+
+	var msg Message
+	if err := json.Unmarshal([]byte(input), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if msg.Edited != "2024-01-15T15:02:00" || msg.EditedUnixtime != "1705320120" {
+		t.Errorf("Edited = %q, EditedUnixtime = %q", msg.Edited, msg.EditedUnixtime)
+	}
+	if len(msg.Reactions) != 2 {
+		t.Fatalf("Reactions = %+v", msg.Reactions)
+	}
+	if msg.Reactions[0].Emoji != "👍" || msg.Reactions[0].Count != 2 || len(msg.Reactions[0].Recent) != 1 {
+		t.Errorf("first reaction = %+v", msg.Reactions[0])
+	}
+	if msg.Reactions[1].DocumentID != "5368324170671202286" {
+		t.Errorf("DocumentID = %q", msg.Reactions[1].DocumentID)
+	}
+}
+
+func TestMessage_UnmarshalServiceDetails(t *testing.T) {
+	input := `[
+		{"id": 1, "type": "service", "date": "2024-01-15T14:30:00", "actor": "Иван",
+		 "action": "invite_members", "members": ["Анна", null], "text": ""},
+		{"id": 2, "type": "service", "date": "2024-01-15T14:31:00", "actor": "Мария",
+		 "action": "pin_message", "message_id": 512, "text": ""},
+		{"id": 3, "type": "service", "date": "2024-01-15T14:32:00", "actor": "Иван",
+		 "action": "phone_call", "duration_seconds": 723, "discard_reason": "busy", "text": ""},
+		{"id": 4, "type": "service", "date": "2024-01-15T14:33:00", "actor": "Пётр",
+		 "action": "join_group_by_link", "inviter": "Group", "text": ""}
+	]`
+
+	var msgs []Message
+	if err := json.Unmarshal([]byte(input), &msgs); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(msgs[0].Members) != 2 || msgs[0].Members[0] != "Анна" || msgs[0].Members[1] != "" {
+		t.Errorf("Members = %q", msgs[0].Members)
+	}
+	if msgs[1].MessageID != 512 {
+		t.Errorf("MessageID = %d, want 512", msgs[1].MessageID)
+	}
+	if msgs[2].DurationSeconds != 723 || msgs[2].DiscardReason != "busy" {
+		t.Errorf("DurationSeconds = %d, DiscardReason = %q", msgs[2].DurationSeconds, msgs[2].DiscardReason)
+	}
+	if msgs[3].Inviter != "Group" {
+		t.Errorf("Inviter = %q, want %q", msgs[3].Inviter, "Group")
+	}
+}
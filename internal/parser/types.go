@@ -3,6 +3,7 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // Chat represents the root structure of Telegram export JSON.
@@ -15,18 +16,134 @@ type Chat struct {
 
 // Message represents a single message in the export.
 type Message struct {
-	ID            int64       `json:"id"`
-	Type          string      `json:"type"`
-	Date          string      `json:"date"`
-	DateUnixtime  string      `json:"date_unixtime,omitempty"`
-	From          string      `json:"from,omitempty"`
-	FromID        string      `json:"from_id,omitempty"`
-	ReplyToMsgID  *int64      `json:"reply_to_message_id,omitempty"`
-	ForwardedFrom string      `json:"forwarded_from,omitempty"`
-	Text          TextContent `json:"text"`
+	ID            int64        `json:"id"`
+	Type          string       `json:"type"`
+	Date          string       `json:"date"`
+	DateUnixtime  string       `json:"date_unixtime,omitempty"`
+	From          string       `json:"from,omitempty"`
+	FromID        string       `json:"from_id,omitempty"`
+	ReplyToMsgID  *int64       `json:"reply_to_message_id,omitempty"`
+	ForwardedFrom string       `json:"forwarded_from,omitempty"`
+	Text          TextContent  `json:"text"`
 	TextEntities  []TextEntity `json:"text_entities,omitempty"`
-	Action        string      `json:"action,omitempty"`
-	Actor         string      `json:"actor,omitempty"`
+	Action        string       `json:"action,omitempty"`
+	Actor         string       `json:"actor,omitempty"`
+	Title         string       `json:"title,omitempty"`
+
+	// Forward origin: ForwardedFromID identifies the source,
+	// ForwardedDate is the date of the original message and SavedFrom
+	// names the chat a message in Saved Messages was saved from
+	ForwardedFromID string `json:"forwarded_from_id,omitempty"`
+	ForwardedDate   string `json:"forwarded_date,omitempty"`
+	SavedFrom       string `json:"saved_from,omitempty"`
+
+	// Service action details; calls also use DurationSeconds
+	Members       []string `json:"members,omitempty"`
+	MessageID     int64    `json:"message_id,omitempty"`
+	Inviter       string   `json:"inviter,omitempty"`
+	DiscardReason string   `json:"discard_reason,omitempty"`
+
+	// NewTitle is set by topic edits that rename the topic, Period by
+	// auto-delete timers, Amount by payments in the minor units of Currency
+	NewTitle        string `json:"new_title,omitempty"`
+	Period          int    `json:"period,omitempty"`
+	ScheduleDate    string `json:"schedule_date,omitempty"`
+	Score           int    `json:"score,omitempty"`
+	Emoticon        string `json:"emoticon,omitempty"`
+	Amount          int64  `json:"amount,omitempty"`
+	Currency        string `json:"currency,omitempty"`
+	Boosts          int    `json:"boosts,omitempty"`
+	InformationText string `json:"information_text,omitempty"`
+
+	// Edits and reactions
+	Edited         string     `json:"edited,omitempty"`
+	EditedUnixtime string     `json:"edited_unixtime,omitempty"`
+	Reactions      []Reaction `json:"reactions,omitempty"`
+
+	// Media attachments
+	Photo           string `json:"photo,omitempty"`
+	PhotoFileSize   int64  `json:"photo_file_size,omitempty"`
+	File            string `json:"file,omitempty"`
+	FileName        string `json:"file_name,omitempty"`
+	FileSize        int64  `json:"file_size,omitempty"`
+	Thumbnail       string `json:"thumbnail,omitempty"`
+	MediaType       string `json:"media_type,omitempty"`
+	MimeType        string `json:"mime_type,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+	Width           int    `json:"width,omitempty"`
+	Height          int    `json:"height,omitempty"`
+	StickerEmoji    string `json:"sticker_emoji,omitempty"`
+
+	// Structured content
+	Poll                      *Poll                `json:"poll,omitempty"`
+	LocationInformation       *LocationInformation `json:"location_information,omitempty"`
+	LiveLocationPeriodSeconds int                  `json:"live_location_period_seconds,omitempty"`
+	PlaceName                 string               `json:"place_name,omitempty"`
+	Address                   string               `json:"address,omitempty"`
+	ContactInformation        *ContactInformation  `json:"contact_information,omitempty"`
+	ContactVcard              string               `json:"contact_vcard,omitempty"`
+	Dice                      *Dice                `json:"dice,omitempty"`
+	GameTitle                 string               `json:"game_title,omitempty"`
+	GameDescription           string               `json:"game_description,omitempty"`
+	GameLink                  string               `json:"game_link,omitempty"`
+}
+
+// Reaction is one reaction kind on a message with its count.
+// Type is "emoji", "custom_emoji" or "paid".
+type Reaction struct {
+	Type       string         `json:"type"`
+	Count      int            `json:"count"`
+	Emoji      string         `json:"emoji,omitempty"`
+	DocumentID string         `json:"document_id,omitempty"`
+	Recent     []ReactionUser `json:"recent,omitempty"`
+}
+
+// ReactionUser is a recent reactor listed in the export.
+type ReactionUser struct {
+	From   string `json:"from"`
+	FromID string `json:"from_id"`
+	Date   string `json:"date"`
+}
+
+// Poll is a poll attached to a message.
+type Poll struct {
+	Question    string       `json:"question"`
+	Closed      bool         `json:"closed"`
+	TotalVoters int          `json:"total_voters"`
+	Answers     []PollAnswer `json:"answers"`
+}
+
+// PollAnswer is a single poll option with its vote count.
+type PollAnswer struct {
+	Text   string `json:"text"`
+	Voters int    `json:"voters"`
+	Chosen bool   `json:"chosen"`
+}
+
+// LocationInformation holds coordinates of a shared location or venue.
+type LocationInformation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// ContactInformation is a shared contact card.
+type ContactInformation struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// Dice is an animated dice roll with its result.
+type Dice struct {
+	Emoji string `json:"emoji"`
+	Value int    `json:"value"`
+}
+
+// IsMissingFile reports whether an exported media path is a placeholder
+// such as "(File not included. Change data exporting settings to download.)"
+// rather than a real file.
+func IsMissingFile(path string) bool {
+	return strings.HasPrefix(path, "(")
 }
 
 // TextContent handles polymorphic text field (string or array of entities).
@@ -41,6 +158,15 @@ type TextEntity struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
 	Href string `json:"href,omitempty"`
+
+	// UserID identifies the user of a mention_name entity.
+	UserID int64 `json:"user_id,omitempty"`
+	// DocumentID identifies the sticker behind a custom_emoji entity.
+	DocumentID string `json:"document_id,omitempty"`
+	// Collapsed marks an expandable_blockquote shown collapsed.
+	Collapsed bool `json:"collapsed,omitempty"`
+	// Language is the syntax highlighting language of a pre entity.
+	Language string `json:"language,omitempty"`
 }
 
 // UnmarshalJSON handles mixed array elements (objects or plain strings).
@@ -48,9 +174,7 @@ func (te *TextEntity) UnmarshalJSON(data []byte) error {
 	// Try plain string first (Telegram puts plain strings directly in array)
 	var plain string
 	if err := json.Unmarshal(data, &plain); err == nil {
-		te.Type = "plain"
-		te.Text = plain
-		te.Href = ""
+		*te = TextEntity{Type: "plain", Text: plain}
 		return nil
 	}
 
@@ -98,4 +222,23 @@ func (tc TextContent) MarshalJSON() ([]byte, error) {
 type ParseResult struct {
 	Message *Message
 	Error   error
+
+	// ExportDir is the folder media paths of Message are relative to when
+	// it differs from the converted export, as in merged exports
+	ExportDir string
+}
+
+// ChatInfo describes a single chat inside an export.
+type ChatInfo struct {
+	Name string
+	Type string
+	ID   int64
+}
+
+// ChatStream pairs a chat from an account-wide export with its messages.
+// Messages must be drained before the next chat is produced.
+type ChatStream struct {
+	Info     ChatInfo
+	Messages <-chan ParseResult
+	Error    error
 }
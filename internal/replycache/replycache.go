@@ -0,0 +1,436 @@
+package replycache
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+// maxText is the longest preview text stored on disk, in bytes, so its
+// length fits in the byte before it. Reply previews are at most 50 runes.
+const maxText = 255
+
+// entrySize is the on-disk size of an index entry: a message ID and the
+// log offset of its preview.
+const entrySize = 16
+
+// fenceEvery is the number of index entries per ID kept in memory, so a
+// lookup reads one block of the index.
+const fenceEvery = 128
+
+// entryOverhead approximates the memory a cached entry costs besides its
+// text: the map entry, string header and eviction queue slot.
+const entryOverhead = 64
+
+// Cache keeps reply previews within a memory budget. Previews beyond the
+// budget are appended to a log file and found through an index sorted by
+// ID, so memory use stays flat and disk use follows the number of spilled
+// previews, not their IDs.
+type Cache struct {
+	dir    string
+	budget int
+	used   int
+
+	memory map[int64]string
+	// order lists IDs in insertion order, oldest are spilled first
+	order []int64
+
+	// log holds the spilled previews, each after its length byte
+	log     *os.File
+	logPath string
+	logSize int64
+	// runs index the log, later runs hold the newer previews of an ID
+	runs []*run
+	err  error
+}
+
+// run is an index file of entries sorted by ID. fences holds the first ID
+// of every fenceEvery entries.
+type run struct {
+	file   *os.File
+	path   string
+	n      int64
+	last   int64
+	fences []int64
+}
+
+// New creates a cache that holds up to budget bytes of previews in memory
+// and spills the rest to temporary files in dir.
+func New(dir string, budget int) *Cache {
+	return &Cache{
+		dir:    dir,
+		budget: budget,
+		memory: make(map[int64]string),
+	}
+}
+
+// Put stores the preview for a message ID.
+func (c *Cache) Put(id int64, preview string) {
+	if old, ok := c.memory[id]; ok {
+		c.used -= len(old) + entryOverhead
+	} else {
+		c.order = append(c.order, id)
+	}
+	c.memory[id] = preview
+	c.used += len(preview) + entryOverhead
+
+	// Each queued entry is visited at most once per call
+	for n := len(c.order); n > 0 && c.used > c.budget; n-- {
+		if !c.evict() {
+			break
+		}
+	}
+}
+
+// Get returns the preview for a message ID.
+func (c *Cache) Get(id int64) (string, bool) {
+	if preview, ok := c.memory[id]; ok {
+		return preview, true
+	}
+	if len(c.runs) == 0 {
+		return "", false
+	}
+	if err := c.open(); err != nil {
+		return "", false
+	}
+
+	for i := len(c.runs) - 1; i >= 0; i-- {
+		if offset, ok := c.runs[i].find(id); ok {
+			return c.read(offset)
+		}
+	}
+	return "", false
+}
+
+// Spill writes every in-memory preview to disk and closes the files until
+// the next lookup. Used to park a fully indexed chat.
+func (c *Cache) Spill() error {
+	for n := len(c.order); n > 0; n-- {
+		if !c.evict() {
+			break
+		}
+	}
+	if err := c.closeFiles(); err != nil && c.err == nil {
+		c.err = fmt.Errorf("close reply index: %w", err)
+	}
+	return c.err
+}
+
+// Err returns the first disk error. Previews that could not be spilled
+// are kept in memory, so lookups still work.
+func (c *Cache) Err() error {
+	return c.err
+}
+
+// Close closes and removes the log and index files.
+func (c *Cache) Close() error {
+	err := c.closeFiles()
+	paths := []string{c.logPath}
+	for _, r := range c.runs {
+		paths = append(paths, r.path)
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if rmErr := os.Remove(path); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	c.logPath = ""
+	c.runs = nil
+	c.memory = nil
+	c.order = nil
+	return err
+}
+
+// evict moves the oldest in-memory preview to disk. Previews that cannot
+// be written go back to the end of the queue. Returns false after a disk
+// error.
+func (c *Cache) evict() bool {
+	id := c.order[0]
+	c.order = c.order[1:]
+
+	preview, ok := c.memory[id]
+	if !ok {
+		return true
+	}
+	if err := c.write(id, preview); err != nil {
+		c.order = append(c.order, id)
+		return false
+	}
+
+	delete(c.memory, id)
+	c.used -= len(preview) + entryOverhead
+
+	// Release the queue's backing array once it is mostly consumed
+	if cap(c.order) > 1024 && len(c.order) < cap(c.order)/4 {
+		c.order = append([]int64(nil), c.order...)
+	}
+	return true
+}
+
+// write appends a preview to the log and its ID to the index.
+func (c *Cache) write(id int64, preview string) error {
+	if err := c.open(); err != nil {
+		return err
+	}
+
+	text := truncate(preview, maxText)
+	record := append([]byte{byte(len(text))}, text...)
+	if _, err := c.log.WriteAt(record, c.logSize); err != nil {
+		c.err = fmt.Errorf("write reply log: %w", err)
+		return c.err
+	}
+
+	r, err := c.tail(id)
+	if err != nil {
+		return err
+	}
+	var entry [entrySize]byte
+	putEntry(entry[:], id, c.logSize)
+	if _, err := r.file.WriteAt(entry[:], r.n*entrySize); err != nil {
+		c.err = fmt.Errorf("write reply index: %w", err)
+		return c.err
+	}
+	r.add(id)
+	c.logSize += int64(len(record))
+	return nil
+}
+
+// read returns the preview stored at offset in the log.
+func (c *Cache) read(offset int64) (string, bool) {
+	var record [1 + maxText]byte
+	// Records at the end of the log are shorter than the buffer
+	n, _ := c.log.ReadAt(record[:], offset)
+	if n == 0 || n < 1+int(record[0]) {
+		return "", false
+	}
+	return string(record[1 : 1+int(record[0])]), true
+}
+
+// tail returns the run an entry for id is appended to. Previews arrive by
+// ID, so a run grows for the whole export; an ID not above the last one
+// starts a new run. Runs no larger than the one after them are merged
+// first, which keeps few runs to search however the IDs arrive.
+func (c *Cache) tail(id int64) (*run, error) {
+	if n := len(c.runs); n > 0 && id > c.runs[n-1].last {
+		return c.runs[n-1], nil
+	}
+	for n := len(c.runs); n >= 2 && c.runs[n-2].n <= c.runs[n-1].n; n = len(c.runs) {
+		if err := c.mergeLast(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := c.create()
+	if err != nil {
+		return nil, err
+	}
+	r := &run{file: file, path: file.Name()}
+	c.runs = append(c.runs, r)
+	return r, nil
+}
+
+// mergeLast replaces the last two runs by one. The newer preview of an ID
+// found in both is kept.
+func (c *Cache) mergeLast() error {
+	older, newer := c.runs[len(c.runs)-2], c.runs[len(c.runs)-1]
+	file, err := c.create()
+	if err != nil {
+		return err
+	}
+	merged := &run{file: file, path: file.Name()}
+
+	a, b := newEntryReader(older), newEntryReader(newer)
+	w := bufio.NewWriter(file)
+	var entry [entrySize]byte
+	for a.ok || b.ok {
+		next := b
+		switch {
+		case !b.ok || a.ok && a.id < b.id:
+			next = a
+		case a.ok && a.id == b.id:
+			a.next()
+		}
+		putEntry(entry[:], next.id, next.offset)
+		w.Write(entry[:])
+		merged.add(next.id)
+		next.next()
+	}
+	if err := cmp.Or(a.err, b.err, w.Flush()); err != nil {
+		file.Close()
+		os.Remove(merged.path)
+		c.err = fmt.Errorf("merge reply index: %w", err)
+		return c.err
+	}
+
+	for _, r := range []*run{older, newer} {
+		r.file.Close()
+		os.Remove(r.path)
+	}
+	c.runs = append(c.runs[:len(c.runs)-2], merged)
+	return nil
+}
+
+// add records an entry appended to the run.
+func (r *run) add(id int64) {
+	if r.n%fenceEvery == 0 {
+		r.fences = append(r.fences, id)
+	}
+	r.n++
+	r.last = id
+}
+
+// find returns the log offset of the preview of id.
+func (r *run) find(id int64) (int64, bool) {
+	if r.n == 0 || id < r.fences[0] || id > r.last {
+		return 0, false
+	}
+	block, found := slices.BinarySearch(r.fences, id)
+	if !found {
+		block--
+	}
+
+	start := int64(block) * fenceEvery
+	buf := make([]byte, min(fenceEvery, r.n-start)*entrySize)
+	if _, err := r.file.ReadAt(buf, start*entrySize); err != nil {
+		return 0, false
+	}
+	n := len(buf) / entrySize
+	i := sort.Search(n, func(i int) bool {
+		entryID, _ := getEntry(buf[i*entrySize:])
+		return entryID >= id
+	})
+	if i == n {
+		return 0, false
+	}
+	entryID, offset := getEntry(buf[i*entrySize:])
+	return offset, entryID == id
+}
+
+// entryReader reads the entries of a run in order.
+type entryReader struct {
+	r          *bufio.Reader
+	id, offset int64
+	ok         bool
+	err        error
+}
+
+func newEntryReader(r *run) *entryReader {
+	er := &entryReader{r: bufio.NewReader(io.NewSectionReader(r.file, 0, r.n*entrySize))}
+	er.next()
+	return er
+}
+
+// next reads the following entry, ok is false past the last one.
+func (er *entryReader) next() {
+	var entry [entrySize]byte
+	if _, err := io.ReadFull(er.r, entry[:]); err != nil {
+		if err != io.EOF {
+			er.err = err
+		}
+		er.ok = false
+		return
+	}
+	er.id, er.offset = getEntry(entry[:])
+	er.ok = true
+}
+
+func putEntry(buf []byte, id, offset int64) {
+	binary.LittleEndian.PutUint64(buf, uint64(id))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(offset))
+}
+
+func getEntry(buf []byte) (id, offset int64) {
+	return int64(binary.LittleEndian.Uint64(buf)), int64(binary.LittleEndian.Uint64(buf[8:]))
+}
+
+// open creates the log on first use and reopens the files after Spill.
+func (c *Cache) open() error {
+	if c.log != nil {
+		return nil
+	}
+	if c.err != nil {
+		return c.err
+	}
+
+	if c.logPath == "" {
+		file, err := c.create()
+		if err != nil {
+			return err
+		}
+		c.log, c.logPath = file, file.Name()
+		return nil
+	}
+
+	// The log is opened last, it marks the files as open
+	for _, r := range c.runs {
+		if r.file != nil {
+			continue
+		}
+		file, err := os.OpenFile(r.path, os.O_RDWR, 0)
+		if err != nil {
+			c.err = fmt.Errorf("open reply index: %w", err)
+			return c.err
+		}
+		r.file = file
+	}
+	file, err := os.OpenFile(c.logPath, os.O_RDWR, 0)
+	if err != nil {
+		c.err = fmt.Errorf("open reply log: %w", err)
+		return c.err
+	}
+	c.log = file
+	return nil
+}
+
+// create makes a temporary file in the cache directory.
+func (c *Cache) create() (*os.File, error) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		c.err = fmt.Errorf("create reply index: %w", err)
+		return nil, c.err
+	}
+	file, err := os.CreateTemp(c.dir, ".tg2md-replies-*")
+	if err != nil {
+		c.err = fmt.Errorf("create reply index: %w", err)
+		return nil, c.err
+	}
+	return file, nil
+}
+
+// closeFiles closes the log and index files, keeping them on disk.
+func (c *Cache) closeFiles() error {
+	var err error
+	if c.log != nil {
+		err = c.log.Close()
+		c.log = nil
+	}
+	for _, r := range c.runs {
+		if r.file == nil {
+			continue
+		}
+		if closeErr := r.file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		r.file = nil
+	}
+	return err
+}
+
+// truncate cuts text to at most n bytes at a rune boundary.
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
@@ -0,0 +1,155 @@
+package replycache
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCache_SpillsBeyondBudget(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 4*(entryOverhead+16))
+	defer c.Close()
+
+	for id := int64(1); id <= 100; id++ {
+		c.Put(id, fmt.Sprintf("сообщение %d", id))
+	}
+
+	if len(c.memory) > 4 {
+		t.Errorf("memory holds %d previews, want at most 4", len(c.memory))
+	}
+	if c.used > c.budget {
+		t.Errorf("used = %d, want at most %d", c.used, c.budget)
+	}
+
+	for id := int64(1); id <= 100; id++ {
+		got, ok := c.Get(id)
+		want := fmt.Sprintf("сообщение %d", id)
+		if !ok || got != want {
+			t.Errorf("Get(%d) = %q, %v, want %q", id, got, ok, want)
+		}
+	}
+
+	if _, ok := c.Get(101); ok {
+		t.Error("Get(101) should not find a message")
+	}
+	if _, ok := c.Get(100000); ok {
+		t.Error("Get(100000) should not find a message")
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestCache_PutReplacesPreview(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	defer c.Close()
+
+	c.Put(1, "старый")
+	c.Put(1, "новый")
+
+	if got, ok := c.Get(1); !ok || got != "новый" {
+		t.Errorf("Get(1) = %q, %v, want %q", got, ok, "новый")
+	}
+}
+
+func TestCache_SpillAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 1<<20)
+
+	c.Put(1, "первое")
+	c.Put(-5, "разделитель")
+	if err := c.Spill(); err != nil {
+		t.Fatalf("Spill failed: %v", err)
+	}
+	if len(c.memory) != 0 {
+		t.Errorf("memory holds %d previews after Spill, want 0", len(c.memory))
+	}
+
+	if got, ok := c.Get(1); !ok || got != "первое" {
+		t.Errorf("Get(1) = %q, %v, want %q", got, ok, "первое")
+	}
+	if got, ok := c.Get(-5); !ok || got != "разделитель" {
+		t.Errorf("Get(-5) = %q, %v, want %q", got, ok, "разделитель")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Close left %d files in the output directory", len(entries))
+	}
+}
+
+func TestCache_DiskFollowsPreviewCount(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 0)
+	defer c.Close()
+
+	for i := int64(0); i < 1000; i++ {
+		c.Put(1<<40+i*1000000, "сообщение")
+	}
+
+	var size int64
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+		size += info.Size()
+	}
+	if size > 1000*(entrySize+1+maxText) {
+		t.Errorf("disk use = %d bytes for 1000 previews", size)
+	}
+	if got, ok := c.Get(1<<40 + 999*1000000); !ok || got != "сообщение" {
+		t.Errorf("Get() = %q, %v, want %q", got, ok, "сообщение")
+	}
+}
+
+func TestCache_OutOfOrderIDs(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	defer c.Close()
+
+	// Descending IDs start a run each, which are merged as they come
+	for id := int64(1000); id >= 1; id-- {
+		c.Put(id, fmt.Sprintf("старое %d", id))
+	}
+	// A second pass replaces the previews spilled by the first
+	for id := int64(1); id <= 1000; id += 2 {
+		c.Put(id, fmt.Sprintf("новое %d", id))
+	}
+	if len(c.runs) > 12 {
+		t.Errorf("index has %d runs, want at most 12", len(c.runs))
+	}
+
+	for id := int64(1); id <= 1000; id++ {
+		want := fmt.Sprintf("старое %d", id)
+		if id%2 == 1 {
+			want = fmt.Sprintf("новое %d", id)
+		}
+		if got, ok := c.Get(id); !ok || got != want {
+			t.Errorf("Get(%d) = %q, %v, want %q", id, got, ok, want)
+		}
+	}
+	if _, ok := c.Get(1001); ok {
+		t.Error("Get(1001) should not find a message")
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := strings.Repeat("я", 200)
+
+	got := truncate(text, maxText)
+	if len(got) > maxText {
+		t.Errorf("truncate() length = %d, want at most %d", len(got), maxText)
+	}
+	if !strings.HasPrefix(text, got) || len(got)%2 != 0 {
+		t.Errorf("truncate() = %q, cut inside a rune", got)
+	}
+}
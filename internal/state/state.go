@@ -0,0 +1,131 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/grigoriizhovtun/tg2md/internal/writer"
+)
+
+// FileName is the state file kept in the group directory.
+const FileName = ".tg2md-state.json"
+
+// State records what earlier runs wrote to a group directory, so the next
+// run over a newer export only adds messages after LastID.
+type State struct {
+	LastID   int64  `json:"last_id"`
+	LastDate string `json:"last_date,omitempty"`
+
+	// Files maps paths relative to the group directory, with forward
+	// slashes, to the written files
+	Files map[string]File `json:"files"`
+
+	// References maps URLs to the labels of reference-style links, so
+	// links added later do not reuse a label defined in a file
+	References map[string]string `json:"references,omitempty"`
+}
+
+// File is a written file with the checksum of its content after the run.
+type File struct {
+	writer.FileState
+	Checksum string `json:"checksum"`
+}
+
+// Load reads the state of dir. A missing state file gives an empty state.
+func Load(dir string) (*State, error) {
+	s := &State{Files: make(map[string]File)}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse state %s: %w", FileName, err)
+	}
+	if s.Files == nil {
+		s.Files = make(map[string]File)
+	}
+	return s, nil
+}
+
+// Save writes the state to dir, replacing the old state file only once
+// the new one is complete.
+func (s *State) Save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// Resume returns the files of dir by absolute path, for Writer.Resume.
+func (s *State) Resume(dir string) map[string]writer.FileState {
+	files := make(map[string]writer.FileState, len(s.Files))
+	for rel, f := range s.Files {
+		files[filepath.Join(dir, filepath.FromSlash(rel))] = f.FileState
+	}
+	return files
+}
+
+// Update records files written under dir, by absolute path, with their
+// current checksums.
+func (s *State) Update(dir string, files map[string]writer.FileState) error {
+	for path, f := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("update state: %w", err)
+		}
+		sum, err := Checksum(path)
+		if err != nil {
+			return err
+		}
+		s.Files[filepath.ToSlash(rel)] = File{FileState: f, Checksum: sum}
+	}
+	return nil
+}
+
+// Edited returns the files of dir changed since the last run, relative to
+// dir. Missing files count as changed.
+func (s *State) Edited(dir string) []string {
+	var edited []string
+	for rel, f := range s.Files {
+		sum, err := Checksum(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil || sum != f.Checksum {
+			edited = append(edited, rel)
+		}
+	}
+	slices.Sort(edited)
+	return edited
+}
+
+// Checksum returns the SHA-256 of a file as a hex string.
+func Checksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("checksum: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", filepath.Base(path), err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/writer"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if s.LastID != 0 || len(s.Files) != 0 {
+		t.Errorf("Load() = %+v, want empty state", s)
+	}
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2024", "01.md")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("сообщение\n\n"), 0644)
+
+	s, _ := Load(dir)
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.Update(dir, map[string]writer.FileState{path: {Period: "january_2024", Start: start}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	s.LastID, s.LastDate = 42, "2024-01-15T14:30:00"
+	if err := s.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.LastID != 42 || loaded.LastDate != "2024-01-15T14:30:00" {
+		t.Errorf("Load() = %+v", loaded)
+	}
+	f, ok := loaded.Files["2024/01.md"]
+	if !ok || f.Period != "january_2024" || !f.Start.Equal(start) || f.Checksum == "" {
+		t.Errorf("Files = %+v", loaded.Files)
+	}
+	if resumed := loaded.Resume(dir); resumed[path].Period != "january_2024" {
+		t.Errorf("Resume() = %+v", resumed)
+	}
+	if edited := loaded.Edited(dir); len(edited) != 0 {
+		t.Errorf("Edited() = %q, want none", edited)
+	}
+
+	os.WriteFile(path, []byte("сообщение\n\nзаметка\n"), 0644)
+	if edited := loaded.Edited(dir); !slices.Equal(edited, []string{"2024/01.md"}) {
+		t.Errorf("Edited() = %q, want [2024/01.md]", edited)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0644)
+	if _, err := Load(dir); err == nil {
+		t.Error("Load should fail on a broken state file")
+	}
+}
@@ -0,0 +1,245 @@
+package threads
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+)
+
+// maxDepth caps indentation so long reply chains stay readable.
+const maxDepth = 6
+
+// entry is a converted message kept for thread reconstruction. The text
+// itself lives in the spool file.
+type entry struct {
+	id       int64
+	parent   int64
+	time     time.Time
+	offset   int64
+	length   int
+	children []int
+	// refs index the link definitions the message uses
+	refs []int
+}
+
+// Builder collects converted messages and writes the threads formed by
+// their reply chains. Message texts are spooled to a temporary file, so
+// only the reply structure is kept in memory.
+type Builder struct {
+	dir     string
+	locale  *locale.Locale
+	spool   *os.File
+	size    int64
+	entries []entry
+	index   map[int64]int
+
+	// definitions are reference-style link definitions by first use,
+	// labels maps their labels to them
+	definitions []string
+	labels      map[string]int
+}
+
+// New creates a Builder that spools message texts to a temporary file in dir.
+// Thread headers are written in loc.
+func New(dir string, loc *locale.Locale) *Builder {
+	return &Builder{
+		dir:    dir,
+		locale: loc,
+		index:  make(map[int64]int),
+		labels: make(map[string]int),
+	}
+}
+
+// Add records a converted message. parent is the ID of the message it
+// replies to, or 0 for messages that start a conversation.
+func (b *Builder) Add(id, parent int64, t time.Time, text string) error {
+	if b.spool == nil {
+		spool, err := os.CreateTemp(b.dir, ".tg2md-threads-*")
+		if err != nil {
+			return fmt.Errorf("create thread spool: %w", err)
+		}
+		b.spool = spool
+	}
+
+	if _, err := b.spool.WriteString(text); err != nil {
+		return fmt.Errorf("write thread spool: %w", err)
+	}
+
+	b.index[id] = len(b.entries)
+	b.entries = append(b.entries, entry{
+		id:     id,
+		parent: parent,
+		time:   t,
+		offset: b.size,
+		length: len(text),
+	})
+	b.size += int64(len(text))
+	return nil
+}
+
+// AddReference records a reference-style link definition used by the last
+// added message. Definitions of the written messages follow the threads.
+func (b *Builder) AddReference(label, url string) {
+	if len(b.entries) == 0 {
+		return
+	}
+	i, ok := b.labels[label]
+	if !ok {
+		i = len(b.definitions)
+		b.labels[label] = i
+		b.definitions = append(b.definitions, fmt.Sprintf("[%s]: %s\n", label, url))
+	}
+	e := &b.entries[len(b.entries)-1]
+	if !slices.Contains(e.refs, i) {
+		e.refs = append(e.refs, i)
+	}
+}
+
+// Write writes every thread, a root message with at least one reply, to
+// path as a section. Threads are sorted by start time and replies are
+// indented by depth. Returns the number of threads; no file is created
+// when there are none.
+func (b *Builder) Write(path string) (int, error) {
+	roots := b.link()
+	if len(roots) == 0 {
+		return 0, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("create threads file: %w", err)
+	}
+	out := bufio.NewWriter(file)
+
+	used := make([]bool, len(b.definitions))
+	for _, root := range roots {
+		if err := b.writeThread(out, root, used); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	for i, definition := range b.definitions {
+		if used[i] {
+			out.WriteString(definition)
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return 0, fmt.Errorf("write threads file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("close threads file: %w", err)
+	}
+	return len(roots), nil
+}
+
+// Close removes the spool file.
+func (b *Builder) Close() error {
+	if b.spool == nil {
+		return nil
+	}
+	name := b.spool.Name()
+	err := b.spool.Close()
+	if rmErr := os.Remove(name); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	b.spool = nil
+	return err
+}
+
+// link attaches replies to their parents and returns the roots that have
+// replies, sorted by time. Replies to messages that were not added start
+// their own thread.
+func (b *Builder) link() []int {
+	var roots []int
+	for i := range b.entries {
+		e := &b.entries[i]
+		if p, ok := b.index[e.parent]; ok && e.parent != 0 && p != i {
+			b.entries[p].children = append(b.entries[p].children, i)
+			continue
+		}
+		roots = append(roots, i)
+	}
+
+	roots = slices.DeleteFunc(roots, func(i int) bool {
+		return len(b.entries[i].children) == 0
+	})
+	slices.SortStableFunc(roots, func(x, y int) int {
+		return b.entries[x].time.Compare(b.entries[y].time)
+	})
+	for i := range b.entries {
+		slices.SortStableFunc(b.entries[i].children, func(x, y int) int {
+			return b.entries[x].time.Compare(b.entries[y].time)
+		})
+	}
+	return roots
+}
+
+// writeThread writes one thread section, walking the tree depth-first,
+// and marks the link definitions its messages use.
+func (b *Builder) writeThread(out *bufio.Writer, root int, used []bool) error {
+	type item struct{ entry, depth int }
+
+	count := 0
+	stack := []item{{root, 0}}
+	var body strings.Builder
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		count++
+
+		text, err := b.text(it.entry)
+		if err != nil {
+			return err
+		}
+		writeIndented(&body, text, min(it.depth, maxDepth))
+		for _, ref := range b.entries[it.entry].refs {
+			used[ref] = true
+		}
+
+		children := b.entries[it.entry].children
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, item{children[i], it.depth + 1})
+		}
+	}
+
+	start := b.entries[root].time.Format("2006-01-02 15:04")
+	fmt.Fprintf(out, "## %s\n\n", b.locale.T("thread", start, count))
+	out.WriteString(body.String())
+	return nil
+}
+
+// text reads a message back from the spool file.
+func (b *Builder) text(i int) (string, error) {
+	e := b.entries[i]
+	buf := make([]byte, e.length)
+	if _, err := b.spool.ReadAt(buf, e.offset); err != nil && err != io.EOF {
+		return "", fmt.Errorf("read thread spool: %w", err)
+	}
+	return string(buf), nil
+}
+
+// writeIndented writes a message nested in depth levels of blockquotes,
+// followed by a blank line.
+func writeIndented(body *strings.Builder, text string, depth int) {
+	prefix := strings.Repeat(">", depth)
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case depth == 0:
+			body.WriteString(line)
+		case line == "":
+			body.WriteString(prefix)
+		default:
+			body.WriteString(prefix + " " + line)
+		}
+		body.WriteString("\n")
+	}
+	body.WriteString("\n")
+}
@@ -0,0 +1,170 @@
+package threads
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, time.January, 15, hour, minute, 0, 0, time.UTC)
+}
+
+func TestBuilder_WritesThreads(t *testing.T) {
+	dir := t.TempDir()
+	b := New(dir, locale.Default())
+	defer b.Close()
+
+	for _, m := range []struct {
+		id, parent int64
+		time       time.Time
+		text       string
+	}{
+		{1, 0, at(10, 0), "root"},
+		{2, 0, at(10, 1), "alone"},
+		{3, 1, at(10, 2), "reply\nsecond line"},
+		{4, 3, at(10, 3), "nested"},
+		{5, 1, at(10, 4), "another reply"},
+		{6, 0, at(9, 0), "early root"},
+		{7, 6, at(10, 5), "late reply"},
+		{8, 99, at(10, 6), "reply to missing"},
+	} {
+		if err := b.Add(m.id, m.parent, m.time, m.text); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	path := filepath.Join(dir, "chat_threads.md")
+	count, err := b.Write(path)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Write() = %d threads, want 2", count)
+	}
+
+	content, _ := os.ReadFile(path)
+	expected := "## Ветка от 2024-01-15 09:00 (сообщений: 2)\n\n" +
+		"early root\n\n" +
+		"> late reply\n\n" +
+		"## Ветка от 2024-01-15 10:00 (сообщений: 4)\n\n" +
+		"root\n\n" +
+		"> reply\n> second line\n\n" +
+		">> nested\n\n" +
+		"> another reply\n\n"
+	if string(content) != expected {
+		t.Errorf("threads file = %q, want %q", content, expected)
+	}
+}
+
+func TestBuilder_WritesReferences(t *testing.T) {
+	dir := t.TempDir()
+	b := New(dir, locale.Default())
+	defer b.Close()
+
+	for _, m := range []struct {
+		id, parent int64
+		text       string
+		refs       [][2]string
+	}{
+		{1, 0, "see [docs][1]", [][2]string{{"1", "https://go.dev/doc"}}},
+		{2, 0, "alone [blog][2]", [][2]string{{"2", "https://go.dev/blog"}}},
+		{3, 1, "[spec][3] and [docs][1]", [][2]string{{"3", "https://go.dev/ref/spec"}, {"1", "https://go.dev/doc"}}},
+	} {
+		if err := b.Add(m.id, m.parent, at(10, int(m.id)), m.text); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		for _, ref := range m.refs {
+			b.AddReference(ref[0], ref[1])
+		}
+	}
+
+	path := filepath.Join(dir, "chat_threads.md")
+	if _, err := b.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Message 2 is not part of a thread, so its definition is left out
+	content, _ := os.ReadFile(path)
+	expected := "## Ветка от 2024-01-15 10:01 (сообщений: 2)\n\n" +
+		"see [docs][1]\n\n" +
+		"> [spec][3] and [docs][1]\n\n" +
+		"[1]: https://go.dev/doc\n" +
+		"[3]: https://go.dev/ref/spec\n"
+	if string(content) != expected {
+		t.Errorf("threads file = %q, want %q", content, expected)
+	}
+}
+
+func TestBuilder_NoThreads(t *testing.T) {
+	dir := t.TempDir()
+	b := New(dir, locale.Default())
+
+	b.Add(1, 0, at(10, 0), "one")
+	b.Add(2, 0, at(10, 1), "two")
+
+	path := filepath.Join(dir, "chat_threads.md")
+	count, err := b.Write(path)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Write() = %d threads, want 0", count)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("threads file should not be created without threads")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Close left %d files behind", len(entries))
+	}
+}
+
+func TestWriteIndented_CapsDepth(t *testing.T) {
+	b := New(t.TempDir(), locale.Default())
+	defer b.Close()
+
+	var parent int64
+	for id := int64(1); id <= maxDepth+3; id++ {
+		b.Add(id, parent, at(10, int(id)), "m")
+		parent = id
+	}
+
+	path := filepath.Join(t.TempDir(), "threads.md")
+	if _, err := b.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	deepest := ">>>>> m\n\n>>>>>> m\n\n>>>>>> m\n\n>>>>>> m\n\n"
+	if got := string(content); len(got) < len(deepest) || got[len(got)-len(deepest):] != deepest {
+		t.Errorf("threads file = %q, want depth capped at %d", got, maxDepth)
+	}
+}
+
+func TestBuilder_LocalizedHeader(t *testing.T) {
+	en, _ := locale.Get("en")
+	b := New(t.TempDir(), en)
+	defer b.Close()
+
+	b.Add(1, 0, at(9, 0), "question")
+	b.Add(2, 1, at(9, 5), "answer")
+
+	path := filepath.Join(t.TempDir(), "threads.md")
+	if _, err := b.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	want := "## Thread from 2024-01-15 09:00 (messages: 2)\n\n"
+	if got := string(content); len(got) < len(want) || got[:len(want)] != want {
+		t.Errorf("threads file = %q, want header %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package writer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultPattern names files like chat_january_2024.md, or chat.md when
+// messages are not split.
+//
+// Patterns may use these placeholders and "/" for subdirectories:
+//
+//	{chat}    sanitized chat name
+//	{period}  period key of the split: january_2024, 2024-W03, 001...
+//	{yyyy}    year of the period, the ISO year for weeks
+//	{mm}      month, 01-12
+//	{dd}      day, 01-31
+//	{month}   month name
+//	{ww}      ISO week, 01-53
+//	{q}       quarter, 1-4
+//	{n}       file number of the size and count splits: 001, 002...
+const DefaultPattern = "{chat}_{period}.md"
+
+var placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// calendarPlaceholders need a split into calendar periods.
+var calendarPlaceholders = map[string]bool{
+	"{yyyy}": true, "{mm}": true, "{dd}": true, "{month}": true, "{ww}": true, "{q}": true,
+}
+
+// distinctPlaceholders lists, for each split, sets of placeholders that
+// tell its periods apart besides {period}; any one set is enough.
+var distinctPlaceholders = map[Split][][]string{
+	SplitDay:     {{"{yyyy}", "{mm}", "{dd}"}, {"{yyyy}", "{month}", "{dd}"}},
+	SplitWeek:    {{"{yyyy}", "{ww}"}},
+	SplitMonth:   {{"{yyyy}", "{mm}"}, {"{yyyy}", "{month}"}},
+	SplitQuarter: {{"{yyyy}", "{q}"}, {"{yyyy}", "{mm}"}, {"{yyyy}", "{month}"}},
+	SplitYear:    {{"{yyyy}"}},
+	SplitSize:    {{"{n}"}},
+	SplitCount:   {{"{n}"}},
+}
+
+// validatePattern checks that pattern is a relative path whose
+// placeholders are known, make sense for split and give each of its
+// periods a file of its own.
+func validatePattern(pattern string, split Split) error {
+	if pattern == "" {
+		return nil
+	}
+	used := make(map[string]bool)
+	for _, ph := range placeholderRe.FindAllString(pattern, -1) {
+		used[ph] = true
+		switch {
+		case ph == "{chat}" || ph == "{period}":
+		case ph == "{n}":
+			if split != SplitSize && split != SplitCount {
+				return fmt.Errorf("placeholder {n} in file name pattern %q needs the size or count split", pattern)
+			}
+		case calendarPlaceholders[ph]:
+			if split == SplitNone || split == SplitSize || split == SplitCount {
+				return fmt.Errorf("placeholder %s in file name pattern %q needs a calendar split", ph, pattern)
+			}
+		default:
+			return fmt.Errorf("unknown placeholder %s in file name pattern %q", ph, pattern)
+		}
+	}
+	sample := placeholderRe.ReplaceAllString(pattern, "x")
+	if !filepath.IsLocal(filepath.FromSlash(sample)) || strings.HasSuffix(sample, "/") {
+		return fmt.Errorf("file name pattern %q must be a relative file path", pattern)
+	}
+
+	sets, ok := distinctPlaceholders[split]
+	if !ok || used["{period}"] {
+		return nil
+	}
+	var alternatives []string
+	for _, set := range sets {
+		if !slices.ContainsFunc(set, func(ph string) bool { return !used[ph] }) {
+			return nil
+		}
+		alternatives = append(alternatives, strings.Join(set, ""))
+	}
+	return fmt.Errorf("file name pattern %q gives several %s periods one file, use {period} or %s",
+		pattern, split, strings.Join(alternatives, " or "))
+}
+
+// renderPattern returns the file path of period p relative to the output
+// directory. An empty period drops "{period}" with its separator, so the
+// default pattern gives chat.md.
+func renderPattern(pattern, chat string, p period, monthName func(time.Month) string) string {
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	if p.key == "" {
+		pattern = strings.NewReplacer("_{period}", "", "-{period}", "").Replace(pattern)
+	}
+	name := placeholderRe.ReplaceAllStringFunc(pattern, func(ph string) string {
+		switch ph {
+		case "{chat}":
+			return chat
+		case "{period}":
+			return p.key
+		case "{yyyy}":
+			return fmt.Sprintf("%04d", p.year)
+		case "{mm}":
+			return fmt.Sprintf("%02d", int(p.start.Month()))
+		case "{dd}":
+			return fmt.Sprintf("%02d", p.start.Day())
+		case "{month}":
+			month := monthNames[p.start.Month()]
+			if monthName != nil {
+				month = monthName(p.start.Month())
+			}
+			return strings.ToLower(month)
+		case "{ww}":
+			_, week := p.start.ISOWeek()
+			return fmt.Sprintf("%02d", week)
+		case "{q}":
+			return fmt.Sprintf("%d", (int(p.start.Month())-1)/3+1)
+		case "{n}":
+			return fmt.Sprintf("%03d", p.index)
+		}
+		return ph
+	})
+	return filepath.FromSlash(name)
+}
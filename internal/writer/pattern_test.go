@@ -0,0 +1,163 @@
+package writer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		split   Split
+		ok      bool
+	}{
+		{"", SplitMonth, true},
+		{"{chat}_{yyyy}-{mm}.md", SplitMonth, true},
+		{"{yyyy}/{mm}.md", SplitMonth, true},
+		{"{chat}_{n}.md", SplitCount, true},
+		{"{chat}.md", SplitNone, true},
+		{"{chat}_{hh}.md", SplitMonth, false},
+		{"{chat}_{n}.md", SplitMonth, false},
+		{"{chat}_{yyyy}.md", SplitSize, false},
+		{"../{chat}_{period}.md", SplitMonth, false},
+		{"/tmp/{period}.md", SplitMonth, false},
+		{"{yyyy}/", SplitYear, false},
+		{"{chat}_{yyyy}.md", SplitMonth, false},
+		{"{chat}_{month}.md", SplitMonth, false},
+		{"{yyyy}/{month}.md", SplitMonth, true},
+		{"{yyyy}/{mm}.md", SplitDay, false},
+		{"{yyyy}/{mm}-{dd}.md", SplitDay, true},
+		{"{yyyy}-Q{q}.md", SplitQuarter, true},
+		{"{yyyy}-{ww}.md", SplitWeek, true},
+		{"{chat}/{period}.md", SplitWeek, true},
+		{"{chat}.md", SplitCount, false},
+	}
+
+	for _, tt := range tests {
+		err := validatePattern(tt.pattern, tt.split)
+		if (err == nil) != tt.ok {
+			t.Errorf("validatePattern(%q, %s) = %v, want ok %v", tt.pattern, tt.split, err, tt.ok)
+		}
+	}
+}
+
+func TestRenderPattern(t *testing.T) {
+	month := period{key: "march_2024", start: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), year: 2024}
+	week := period{key: "2024-W03", start: time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC), year: 2024}
+
+	tests := []struct {
+		pattern string
+		p       period
+		want    string
+	}{
+		{"", month, "Chat_march_2024.md"},
+		{"", period{}, "Chat.md"},
+		{"{chat}_{yyyy}-{mm}.md", month, "Chat_2024-03.md"},
+		{"{yyyy}/{mm}_{month}.md", month, filepath.Join("2024", "03_march.md")},
+		{"{yyyy}-Q{q}.md", month, "2024-Q1.md"},
+		{"{yyyy}/W{ww}-{dd}.md", week, filepath.Join("2024", "W03-15.md")},
+		{"{chat}/{n}.md", period{key: "002", index: 2}, filepath.Join("Chat", "002.md")},
+	}
+
+	for _, tt := range tests {
+		if got := renderPattern(tt.pattern, "Chat", tt.p, nil); got != tt.want {
+			t.Errorf("renderPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestWriter_PatternNestsDirectories(t *testing.T) {
+	tempDir := t.TempDir()
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := w.SetSplit(SplitOptions{Split: SplitMonth, Pattern: "{yyyy}/{chat}_{yyyy}-{mm}.md"}); err != nil {
+		t.Fatalf("SetSplit failed: %v", err)
+	}
+
+	dec := time.Date(2023, time.December, 31, 10, 0, 0, 0, time.UTC)
+	jan := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+	want := filepath.Join(tempDir, "Test_Chat", "2024", "Test_Chat_2024-01.md")
+	if got := w.FileFor(jan); got != want {
+		t.Errorf("FileFor() = %q, want %q", got, want)
+	}
+
+	w.WriteMessage("old", dec)
+	w.WriteMessage("new", jan)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(tempDir, "Test_Chat", "2023", "Test_Chat_2023-12.md"),
+		want,
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s not created: %v", path, err)
+		}
+	}
+}
+
+func TestWriter_PatternWeekOfYearBoundary(t *testing.T) {
+	tempDir := t.TempDir()
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := w.SetSplit(SplitOptions{Split: SplitWeek, Pattern: "{yyyy}-W{ww}.md"}); err != nil {
+		t.Fatalf("SetSplit failed: %v", err)
+	}
+
+	// 2024-12-31 is in 2025-W01, which must not collide with 2024-W01
+	for _, ts := range []time.Time{
+		time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, time.December, 31, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, time.January, 2, 10, 0, 0, 0, time.UTC),
+	} {
+		if err := w.WriteMessage("message", ts); err != nil {
+			t.Fatalf("WriteMessage(%v) failed: %v", ts, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	for _, name := range []string{"2024-W01.md", "2025-W01.md"} {
+		if _, err := os.Stat(filepath.Join(tempDir, "Test_Chat", name)); err != nil {
+			t.Errorf("%s not created: %v", name, err)
+		}
+	}
+	if w.GetFileCount() != 2 {
+		t.Errorf("FileCount = %d, want 2", w.GetFileCount())
+	}
+}
+
+func TestWriter_PatternCollision(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	// Months of a year would share one file, so the pattern is rejected
+	// before any message is written
+	err = w.SetSplit(SplitOptions{Split: SplitMonth, Pattern: "{chat}_{yyyy}.md"})
+	if err == nil || !strings.Contains(err.Error(), "{yyyy}{mm}") {
+		t.Errorf("SetSplit() = %v, want a pattern error", err)
+	}
+	if err := (SplitOptions{Pattern: "{chat}_{yyyy}.md"}).Validate(); err == nil {
+		t.Error("Validate() should reject a pattern without the month")
+	}
+
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("file written: %s", path)
+		}
+		return nil
+	})
+}
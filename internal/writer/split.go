@@ -0,0 +1,186 @@
+package writer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Split selects how messages are divided into files.
+type Split string
+
+const (
+	// SplitDay writes a file per day: chat_2024-01-15.md.
+	SplitDay Split = "day"
+	// SplitWeek writes a file per ISO week: chat_2024-W03.md.
+	SplitWeek Split = "week"
+	// SplitMonth writes a file per month: chat_january_2024.md.
+	SplitMonth Split = "month"
+	// SplitQuarter writes a file per quarter: chat_2024-Q1.md.
+	SplitQuarter Split = "quarter"
+	// SplitYear writes a file per year: chat_2024.md.
+	SplitYear Split = "year"
+	// SplitNone writes all messages to chat.md.
+	SplitNone Split = "none"
+	// SplitSize starts a new file when the current one reaches MaxBytes:
+	// chat_001.md, chat_002.md...
+	SplitSize Split = "size"
+	// SplitCount starts a new file every MaxMessages messages.
+	SplitCount Split = "count"
+)
+
+// ParseSplit validates a split name from the command line.
+func ParseSplit(name string) (Split, error) {
+	switch split := Split(name); split {
+	case SplitDay, SplitWeek, SplitMonth, SplitQuarter, SplitYear, SplitNone, SplitSize, SplitCount:
+		return split, nil
+	}
+	return "", fmt.Errorf("unknown split %q (want day, week, month, quarter, year, none, size or count)", name)
+}
+
+// SplitOptions configures how a Writer divides messages into files.
+type SplitOptions struct {
+	Split Split
+
+	// DayStart is the hour a day begins at for period splits, so messages
+	// written after midnight stay with the previous day
+	DayStart int
+
+	// MaxBytes and MaxMessages cap files of the size and count splits
+	MaxBytes    int
+	MaxMessages int
+
+	// MonthName names months in file names, nil means English
+	MonthName func(time.Month) string
+
+	// Pattern names files relative to the output directory, see
+	// DefaultPattern; empty means the default
+	Pattern string
+}
+
+// period identifies the file a message belongs to.
+type period struct {
+	// key names the period, e.g. "january_2024"; empty when not split
+	key string
+	// start is the beginning of a calendar period
+	start time.Time
+	// year is the year of a calendar period, the ISO year for weeks
+	year int
+	// index numbers the files of size and count splits from 1
+	index int
+}
+
+// strategy assigns messages to file periods.
+type strategy interface {
+	// next returns the period of a message of the given size and
+	// accounts for it
+	next(t time.Time, size int) period
+	// peek returns the period a message sent at t would go to
+	peek(t time.Time) period
+}
+
+// Validate checks that the options describe a usable split. The zero
+// Split means SplitMonth.
+func (o SplitOptions) Validate() error {
+	if o.DayStart < 0 || o.DayStart > 23 {
+		return fmt.Errorf("day start hour %d out of range 0-23", o.DayStart)
+	}
+	split := o.Split
+	if split == "" {
+		split = SplitMonth
+	}
+	switch split {
+	case SplitSize:
+		if o.MaxBytes <= 0 {
+			return fmt.Errorf("size split needs a positive file size")
+		}
+	case SplitCount:
+		if o.MaxMessages <= 0 {
+			return fmt.Errorf("count split needs a positive message count")
+		}
+	}
+	if _, err := ParseSplit(string(split)); err != nil {
+		return err
+	}
+	return validatePattern(o.Pattern, split)
+}
+
+// newStrategy validates opts and returns their strategy.
+func newStrategy(opts SplitOptions) (strategy, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	switch opts.Split {
+	case SplitSize:
+		return &chunkStrategy{maxBytes: opts.MaxBytes}, nil
+	case SplitCount:
+		return &chunkStrategy{maxMessages: opts.MaxMessages}, nil
+	case "":
+		opts.Split = SplitMonth
+	}
+	return periodStrategy{opts}, nil
+}
+
+// periodStrategy names files after the calendar period of their messages.
+type periodStrategy struct {
+	opts SplitOptions
+}
+
+func (s periodStrategy) next(t time.Time, _ int) period {
+	return s.peek(t)
+}
+
+func (s periodStrategy) peek(t time.Time) period {
+	t = t.Add(-time.Duration(s.opts.DayStart) * time.Hour)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch s.opts.Split {
+	case SplitDay:
+		return period{key: day.Format("2006-01-02"), start: day, year: day.Year()}
+	case SplitWeek:
+		year, week := t.ISOWeek()
+		monday := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return period{key: fmt.Sprintf("%d-W%02d", year, week), start: monday, year: year}
+	case SplitQuarter:
+		quarter := (int(t.Month())-1)/3 + 1
+		start := time.Date(t.Year(), time.Month(quarter*3-2), 1, 0, 0, 0, 0, t.Location())
+		return period{key: fmt.Sprintf("%d-Q%d", t.Year(), quarter), start: start, year: t.Year()}
+	case SplitYear:
+		start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+		return period{key: fmt.Sprintf("%d", t.Year()), start: start, year: t.Year()}
+	case SplitNone:
+		return period{}
+	}
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return period{key: getMonthKey(t, s.opts.MonthName), start: start, year: t.Year()}
+}
+
+// chunkStrategy fills numbered files in arrival order up to a size or a
+// message count.
+type chunkStrategy struct {
+	maxBytes, maxMessages int
+
+	index, bytes, messages int
+}
+
+func (s *chunkStrategy) next(_ time.Time, size int) period {
+	full := s.maxMessages > 0 && s.messages >= s.maxMessages ||
+		s.maxBytes > 0 && s.bytes+size > s.maxBytes
+	if s.index == 0 || full && s.messages > 0 {
+		s.index++
+		s.bytes, s.messages = 0, 0
+	}
+	s.bytes += size
+	s.messages++
+	return s.current()
+}
+
+// peek returns the current file; the next message may still start a new
+// one once its size is known.
+func (s *chunkStrategy) peek(time.Time) period {
+	return s.current()
+}
+
+func (s *chunkStrategy) current() period {
+	index := max(s.index, 1)
+	return period{key: fmt.Sprintf("%03d", index), index: index}
+}
@@ -0,0 +1,120 @@
+package writer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPeriodStrategy(t *testing.T) {
+	// Sunday night after midnight, in ISO week 3 of 2024
+	late := time.Date(2024, time.January, 22, 2, 30, 0, 0, time.UTC)
+	// New Year's night belongs to the old year with a day start at 4
+	newYear := time.Date(2025, time.January, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		split    Split
+		dayStart int
+		time     time.Time
+		want     string
+	}{
+		{SplitDay, 0, late, "2024-01-22"},
+		{SplitDay, 4, late, "2024-01-21"},
+		{SplitWeek, 0, late, "2024-W04"},
+		{SplitWeek, 4, late, "2024-W03"},
+		{SplitMonth, 0, late, "january_2024"},
+		{SplitQuarter, 0, time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC), "2024-Q3"},
+		{SplitYear, 0, late, "2024"},
+		{SplitYear, 4, newYear, "2024"},
+		{SplitMonth, 4, newYear, "december_2024"},
+		{SplitNone, 0, late, ""},
+	}
+
+	for _, tt := range tests {
+		s, err := newStrategy(SplitOptions{Split: tt.split, DayStart: tt.dayStart})
+		if err != nil {
+			t.Fatalf("newStrategy(%q) failed: %v", tt.split, err)
+		}
+		if got := s.next(tt.time, 10).key; got != tt.want {
+			t.Errorf("%s (day start %d).next(%v) = %q, want %q", tt.split, tt.dayStart, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestChunkStrategy(t *testing.T) {
+	now := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+	count, _ := newStrategy(SplitOptions{Split: SplitCount, MaxMessages: 2})
+	var got []string
+	for range 5 {
+		got = append(got, count.next(now, 10).key)
+	}
+	if want := []string{"001", "001", "002", "002", "003"}; !slices.Equal(got, want) {
+		t.Errorf("count periods = %q, want %q", got, want)
+	}
+
+	// An oversized message still gets a file of its own
+	size, _ := newStrategy(SplitOptions{Split: SplitSize, MaxBytes: 100})
+	got = nil
+	for _, n := range []int{40, 50, 20, 500, 10} {
+		got = append(got, size.next(now, n).key)
+	}
+	if want := []string{"001", "001", "002", "003", "004"}; !slices.Equal(got, want) {
+		t.Errorf("size periods = %q, want %q", got, want)
+	}
+}
+
+func TestSplitOptions_Validate(t *testing.T) {
+	invalid := []SplitOptions{
+		{Split: SplitDay, DayStart: 24},
+		{Split: SplitSize},
+		{Split: SplitCount, MaxMessages: -1},
+		{Split: "hourly"},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", opts)
+		}
+	}
+	if err := (SplitOptions{}).Validate(); err != nil {
+		t.Errorf("Validate() of zero options failed: %v", err)
+	}
+}
+
+func TestWriter_SplitNamesFiles(t *testing.T) {
+	tests := []struct {
+		opts SplitOptions
+		want []string
+	}{
+		{SplitOptions{Split: SplitNone}, []string{"Test_Chat.md"}},
+		{SplitOptions{Split: SplitDay}, []string{"Test_Chat_2024-01-15.md", "Test_Chat_2024-01-16.md"}},
+		{SplitOptions{Split: SplitCount, MaxMessages: 1}, []string{"Test_Chat_001.md", "Test_Chat_002.md"}},
+	}
+
+	for _, tt := range tests {
+		tempDir := t.TempDir()
+		w, err := New(tempDir, "Test Chat")
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if err := w.SetSplit(tt.opts); err != nil {
+			t.Fatalf("SetSplit failed: %v", err)
+		}
+		w.WriteMessage("one", time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC))
+		w.WriteMessage("two", time.Date(2024, time.January, 16, 10, 0, 0, 0, time.UTC))
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		for _, name := range tt.want {
+			if _, err := os.Stat(filepath.Join(tempDir, "Test_Chat", name)); err != nil {
+				t.Errorf("%s split: %s not created", tt.opts.Split, name)
+			}
+		}
+		if w.GetFileCount() != len(tt.want) {
+			t.Errorf("%s split: FileCount = %d, want %d", tt.opts.Split, w.GetFileCount(), len(tt.want))
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package writer
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// recordHeader is the size of a spilled record before its text: time,
+// sequence number and text length.
+const recordHeader = 8 + 8 + 4
+
+// spillLargest writes the largest message buffer of the writers sharing
+// the budget to the spool of its writer as a sorted run, freeing its
+// memory.
+func (b *SortBudget) spillLargest() error {
+	var owner *Writer
+	var largest *outputFile
+	for _, w := range b.writers {
+		for _, f := range w.files {
+			if largest == nil || f.size > largest.size {
+				owner, largest = w, f
+			}
+		}
+	}
+	if largest == nil || len(largest.buffer) == 0 {
+		// Nothing left to spill
+		b.used = 0
+		return nil
+	}
+	return owner.spill(largest)
+}
+
+// spill writes the message buffer of a file to the spool as a sorted run.
+func (w *Writer) spill(f *outputFile) error {
+	if w.spool == nil {
+		spool, err := os.CreateTemp(w.outputDir, ".tg2md-sort-*")
+		if err != nil {
+			return fmt.Errorf("create spool: %w", err)
+		}
+		w.spool = spool
+	}
+
+	sortRecords(f.buffer)
+	out := bufio.NewWriter(io.NewOffsetWriter(w.spool, w.spoolSize))
+	var size int64
+	var header [recordHeader]byte
+	for _, r := range f.buffer {
+		binary.LittleEndian.PutUint64(header[0:], uint64(r.time))
+		binary.LittleEndian.PutUint64(header[8:], uint64(r.seq))
+		binary.LittleEndian.PutUint32(header[16:], uint32(len(r.text)))
+		out.Write(header[:])
+		out.WriteString(r.text)
+		size += recordHeader + int64(len(r.text))
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("write spool: %w", err)
+	}
+
+	f.segments = append(f.segments, segment{offset: w.spoolSize, size: size})
+	w.spoolSize += size
+	w.budget.used -= f.size
+	f.buffer = nil
+	f.size = 0
+	return nil
+}
+
+// sortRecords orders records by time, keeping arrival order for equal
+// times.
+func sortRecords(records []record) {
+	slices.SortFunc(records, compareRecords)
+}
+
+func compareRecords(a, b record) int {
+	if c := cmp.Compare(a.time, b.time); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+// recordSource yields sorted records from a buffer or a spilled run.
+type recordSource struct {
+	buffer []record
+	reader *bufio.Reader
+
+	current record
+	ok      bool
+}
+
+// next advances to the following record.
+func (s *recordSource) next() error {
+	if s.reader == nil {
+		s.ok = len(s.buffer) > 0
+		if s.ok {
+			s.current, s.buffer = s.buffer[0], s.buffer[1:]
+		}
+		return nil
+	}
+
+	var header [recordHeader]byte
+	if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+		s.ok = false
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("read spool: %w", err)
+	}
+	text := make([]byte, binary.LittleEndian.Uint32(header[16:]))
+	if _, err := io.ReadFull(s.reader, text); err != nil {
+		s.ok = false
+		return fmt.Errorf("read spool: %w", err)
+	}
+	s.current = record{
+		time: int64(binary.LittleEndian.Uint64(header[0:])),
+		seq:  int64(binary.LittleEndian.Uint64(header[8:])),
+		text: string(text),
+	}
+	s.ok = true
+	return nil
+}
+
+// mergeRecords calls emit for the records of all sources in sorted order.
+// Sources are few, so the smallest is found by a linear scan.
+func mergeRecords(sources []*recordSource, emit func(record) error) error {
+	for _, s := range sources {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	for {
+		var min *recordSource
+		for _, s := range sources {
+			if s.ok && (min == nil || compareRecords(s.current, min.current) < 0) {
+				min = s
+			}
+		}
+		if min == nil {
+			return nil
+		}
+		if err := emit(min.current); err != nil {
+			return err
+		}
+		if err := min.next(); err != nil {
+			return err
+		}
+	}
+}
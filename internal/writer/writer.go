@@ -3,15 +3,18 @@ package writer
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/grigoriizhovtun/tg2md/internal/sanitizer"
 )
 
-// monthNames maps month number to English lowercase name.
+// monthNames maps month number to English lowercase name, used in file
+// names unless SplitOptions.MonthName is set.
 var monthNames = []string{
 	"",         // 0 - not used
 	"january",
@@ -28,16 +31,129 @@ var monthNames = []string{
 	"december",
 }
 
-// Writer handles file output with monthly splitting.
+// DefaultSortBudget is the memory for buffered messages, in bytes. Beyond
+// it messages are spilled to disk in sorted runs.
+const DefaultSortBudget = 32 << 20
+
+// SortBudget is the memory for buffered messages shared by writers, e.g.
+// the writer of a group and those of its topics. Beyond it the largest
+// buffer of any of them is spilled.
+type SortBudget struct {
+	limit   int
+	used    int
+	writers []*Writer
+}
+
+// NewSortBudget creates a budget of limit bytes.
+func NewSortBudget(limit int) *SortBudget {
+	return &SortBudget{limit: limit}
+}
+
+// recordOverhead approximates the memory a buffered message costs besides
+// its text.
+const recordOverhead = 48
+
+// Writer handles file output split by month or another strategy, see
+// SetSplit. Messages may arrive in any date order: each file is written
+// once on Close with its messages sorted by time, so a period seen again
+// never overwrites earlier output.
 type Writer struct {
 	outputDir     string
 	groupName     string
 	sanitizedName string
-	currentFile   *os.File
-	currentWriter *bufio.Writer
-	currentMonth  string
 	stats         map[string]int
-	fileCount     int
+
+	// files holds the buffered output files by period, last is the file
+	// of the last written message
+	files map[string]*outputFile
+	last  *outputFile
+	seq   int64
+
+	// budget caps buffered message text
+	budget *SortBudget
+
+	// spool holds sorted runs of messages spilled from the buffers
+	spool     *os.File
+	spoolSize int64
+
+	// runs maps message IDs to files as ranges of consecutive writes,
+	// so the mapping stays small however many messages are written
+	runs []messageRun
+
+	// header and footer render text around the messages of each file
+	header, footer func(period string, start time.Time) (string, error)
+
+	// split assigns messages to file periods
+	split strategy
+
+	// pattern and monthName name the file of each period
+	pattern   string
+	monthName func(time.Month) string
+
+	// owners maps file paths to their periods to catch patterns that map
+	// two periods to one file; collision is reported again by Close
+	owners    map[string]string
+	collision error
+
+	// resumed holds files of an earlier run that new messages are
+	// appended to, by path
+	resumed map[string]FileState
+
+	closed bool
+}
+
+// outputFile is a period file whose messages are collected until Close.
+type outputFile struct {
+	period string
+	path   string
+	start  time.Time
+
+	buffer   []record
+	size     int
+	segments []segment
+
+	// references holds link definitions written at the end of the file,
+	// in order of first use
+	references []string
+	defined    map[string]bool
+
+	// resumed is the state of the file from an earlier run, nil for a
+	// new file; state is set once the file is written
+	resumed *FileState
+	state   *FileState
+}
+
+// FileState records a written file so that a later run can append to it:
+// new messages replace the link definitions and footer at its end.
+type FileState struct {
+	Period     string    `json:"period"`
+	Start      time.Time `json:"start"`
+	References []string  `json:"references,omitempty"`
+	Footer     string    `json:"footer,omitempty"`
+}
+
+// tail returns the text written after the messages.
+func (s FileState) tail() string {
+	return strings.Join(s.References, "") + s.Footer
+}
+
+// record is a buffered message. seq keeps messages with equal times in
+// arrival order.
+type record struct {
+	time int64
+	seq  int64
+	text string
+}
+
+// segment is a sorted run of records in the spool file.
+type segment struct {
+	offset, size int64
+}
+
+// messageRun is a range of message IDs written to one file.
+type messageRun struct {
+	first, last int64
+	path        string
 }
 
 // New creates a new Writer for the given group.
@@ -50,12 +166,23 @@ func New(basePath, groupName string) (*Writer, error) {
 		return nil, fmt.Errorf("create directory: %w", err)
 	}
 
-	return &Writer{
+	w := &Writer{
 		outputDir:     outputDir,
 		groupName:     groupName,
 		sanitizedName: sanitizedName,
 		stats:         make(map[string]int),
-	}, nil
+		files:         make(map[string]*outputFile),
+		owners:        make(map[string]string),
+		split:         periodStrategy{SplitOptions{Split: SplitMonth}},
+	}
+	w.SetSortBudget(NewSortBudget(DefaultSortBudget))
+	return w, nil
+}
+
+// fileExists reports whether path is an existing file.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
 
 // GetOutputDir returns the output directory path.
@@ -63,87 +190,359 @@ func (w *Writer) GetOutputDir() string {
 	return w.outputDir
 }
 
-// WriteMessage writes a message to the appropriate monthly file.
+// SetSortBudget sets the memory for buffered messages, which may be
+// shared with other writers. Call it before the first WriteMessage.
+func (w *Writer) SetSortBudget(budget *SortBudget) {
+	budget.writers = append(budget.writers, w)
+	w.budget = budget
+}
+
+// SetSplit sets how messages are divided into files. Call it before the
+// first WriteMessage.
+func (w *Writer) SetSplit(opts SplitOptions) error {
+	split, err := newStrategy(opts)
+	if err != nil {
+		return err
+	}
+	w.split = split
+	w.pattern = opts.Pattern
+	w.monthName = opts.MonthName
+	return nil
+}
+
+// FileFor returns the path of the file a message sent at t would be
+// written to. Size and count splits report their current file.
+func (w *Writer) FileFor(t time.Time) string {
+	return w.filePath(w.split.peek(t))
+}
+
+func (w *Writer) filePath(p period) string {
+	return filepath.Join(w.outputDir, renderPattern(w.pattern, w.sanitizedName, p, w.monthName))
+}
+
+// WriteMessage adds a message to the file of its period.
 func (w *Writer) WriteMessage(formattedLine string, timestamp time.Time) error {
-	monthKey := getMonthKey(timestamp)
+	if w.closed {
+		return fmt.Errorf("write message: writer is closed")
+	}
+	// Messages are separated by a blank line
+	p := w.split.next(timestamp, len(formattedLine)+2)
+	period := p.key
 
-	// Switch file if month changed
-	if monthKey != w.currentMonth {
-		if err := w.switchToMonth(monthKey); err != nil {
+	f := w.files[period]
+	if f == nil {
+		path := w.filePath(p)
+		if owner, ok := w.owners[path]; ok {
+			err := fmt.Errorf("file name pattern maps periods %q and %q to %s", owner, period, path)
+			if w.collision == nil {
+				w.collision = err
+			}
 			return err
 		}
+		w.owners[path] = period
+		f = &outputFile{
+			period: period,
+			path:   path,
+			start:  timestamp,
+		}
+		if resumed, ok := w.resumed[path]; ok && fileExists(path) {
+			f.resumed = &resumed
+			f.start = resumed.Start
+			f.defined = make(map[string]bool)
+			for _, ref := range resumed.References {
+				f.defined[referenceLabel(ref)] = true
+			}
+		}
+		w.files[period] = f
 	}
-
-	// Write message with blank line separator
-	_, err := w.currentWriter.WriteString(formattedLine + "\n\n")
-	if err != nil {
-		return fmt.Errorf("write message: %w", err)
+	if timestamp.Before(f.start) {
+		f.start = timestamp
 	}
 
-	w.stats[monthKey]++
+	w.seq++
+	f.buffer = append(f.buffer, record{time: timestamp.UnixNano(), seq: w.seq, text: formattedLine})
+	f.size += len(formattedLine) + recordOverhead
+	w.budget.used += len(formattedLine) + recordOverhead
+	w.stats[period]++
+	w.last = f
+
+	for w.budget.used > w.budget.limit {
+		if err := w.budget.spillLargest(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetStats returns the monthly breakdown of written messages.
+// SetFrame sets functions rendering the header and footer of each file
+// from its period, e.g. "january_2024", and the time of its first
+// message. Either may be nil.
+func (w *Writer) SetFrame(header, footer func(period string, start time.Time) (string, error)) {
+	w.header = header
+	w.footer = footer
+}
+
+// Resume sets files of an earlier run, by path, that messages of their
+// periods are appended to instead of rewriting them. Files without new
+// messages are left untouched. Call it before the first WriteMessage.
+func (w *Writer) Resume(files map[string]FileState) {
+	w.resumed = files
+}
+
+// Files returns the state of the files written by Close, by path.
+func (w *Writer) Files() map[string]FileState {
+	files := make(map[string]FileState)
+	for _, f := range w.files {
+		if f.state != nil {
+			files[f.path] = *f.state
+		}
+	}
+	return files
+}
+
+// MarkMessage records that the message with the given ID was written to
+// the file of the last WriteMessage.
+func (w *Writer) MarkMessage(id int64) {
+	if w.last == nil {
+		return
+	}
+	w.addRun(id, w.last.path)
+}
+
+// MarkWritten records that the message with the given ID, sent at t, was
+// written by an earlier run, so replies can still link to it.
+func (w *Writer) MarkWritten(id int64, t time.Time) {
+	w.addRun(id, w.FileFor(t))
+}
+
+// addRun extends the last run of IDs in path or starts a new one.
+func (w *Writer) addRun(id int64, path string) {
+	if n := len(w.runs); n > 0 {
+		run := &w.runs[n-1]
+		if run.path == path && id > run.last {
+			run.last = id
+			return
+		}
+	}
+	w.runs = append(w.runs, messageRun{first: id, last: id, path: path})
+}
+
+// Locate returns the path of the file a message was written to. Messages
+// are located by the ID ranges of consecutive writes, so an ID skipped
+// inside a range resolves to the file of its neighbours.
+func (w *Writer) Locate(id int64) (string, bool) {
+	for i := len(w.runs) - 1; i >= 0; i-- {
+		if run := w.runs[i]; id >= run.first && id <= run.last {
+			return run.path, true
+		}
+	}
+	return "", false
+}
+
+// AddReference records a reference-style link definition used by the last
+// written message. Definitions are written once at the end of each file.
+func (w *Writer) AddReference(label, url string) {
+	f := w.last
+	if f == nil {
+		return
+	}
+	if f.defined == nil {
+		f.defined = make(map[string]bool)
+	}
+	if f.defined[label] {
+		return
+	}
+	f.defined[label] = true
+	f.references = append(f.references, fmt.Sprintf("[%s]: %s\n", label, url))
+}
+
+// GetStats returns the breakdown of written messages by file period.
 func (w *Writer) GetStats() map[string]int {
 	return w.stats
 }
 
 // GetFileCount returns the number of files created.
 func (w *Writer) GetFileCount() int {
-	return w.fileCount
+	return len(w.files)
 }
 
-// Close flushes and closes any open files.
+// Close writes every file with its messages sorted by time and removes
+// the spool. Files are written in period order. Close fails if the file
+// name pattern mapped two periods to one file.
 func (w *Writer) Close() error {
-	if w.currentWriter != nil {
-		if err := w.currentWriter.Flush(); err != nil {
-			return fmt.Errorf("flush writer: %w", err)
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	// Buffers are released as the files are written
+	w.budget.writers = slices.DeleteFunc(w.budget.writers, func(other *Writer) bool { return other == w })
+	files := make([]*outputFile, 0, len(w.files))
+	for _, f := range w.files {
+		files = append(files, f)
+		w.budget.used -= f.size
+	}
+	slices.SortFunc(files, func(a, b *outputFile) int {
+		return a.start.Compare(b.start)
+	})
+
+	var err error
+	for _, f := range files {
+		if err = w.writeFile(f); err != nil {
+			break
 		}
 	}
-	if w.currentFile != nil {
-		if err := w.currentFile.Close(); err != nil {
-			return fmt.Errorf("close file: %w", err)
+
+	if w.spool != nil {
+		name := w.spool.Name()
+		if closeErr := w.spool.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("close spool: %w", closeErr)
 		}
+		if rmErr := os.Remove(name); rmErr != nil && err == nil {
+			err = fmt.Errorf("remove spool: %w", rmErr)
+		}
+		w.spool = nil
 	}
-	return nil
+	if err == nil {
+		err = w.collision
+	}
+	return err
+}
+
+// writeFile writes a file: header, messages merged from the buffer and
+// spilled runs, link definitions and footer.
+func (w *Writer) writeFile(f *outputFile) error {
+	if f.resumed != nil {
+		return w.appendFile(f)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return fmt.Errorf("create file %s: %w", filepath.Base(f.path), err)
+	}
+	out := bufio.NewWriter(file)
+
+	_, err = writeFrame(out, w.header, f)
+	if err == nil {
+		err = w.writeContent(out, f, f.references)
+	}
+	return finishFile(file, out, err)
 }
 
-// switchToMonth closes current file and opens a new one for the given month.
-func (w *Writer) switchToMonth(monthKey string) error {
-	// Close current file if open
-	if w.currentWriter != nil {
-		if err := w.currentWriter.Flush(); err != nil {
-			return fmt.Errorf("flush writer: %w", err)
+// appendFile adds new messages to a file of an earlier run. Its link
+// definitions and footer are moved after the new messages when the file
+// still ends with them, otherwise text added at the end is kept and new
+// messages follow it.
+func (w *Writer) appendFile(f *outputFile) error {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("read file %s: %w", filepath.Base(f.path), err)
+	}
+	references := f.references
+	if tail := f.resumed.tail(); strings.HasSuffix(string(content), tail) {
+		content = content[:len(content)-len(tail)]
+		references = append(slices.Clone(f.resumed.References), f.references...)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", filepath.Base(f.path), err)
+	}
+	if err := file.Truncate(int64(len(content))); err != nil {
+		file.Close()
+		return fmt.Errorf("truncate file %s: %w", filepath.Base(f.path), err)
+	}
+	if _, err := file.Seek(int64(len(content)), io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("seek file %s: %w", filepath.Base(f.path), err)
+	}
+	out := bufio.NewWriter(file)
+
+	// Messages are separated by a blank line from text edited by hand
+	text := string(content)
+	if text != "" && !strings.HasSuffix(text, "\n\n") {
+		if !strings.HasSuffix(text, "\n") {
+			out.WriteString("\n")
 		}
+		out.WriteString("\n")
 	}
-	if w.currentFile != nil {
-		if err := w.currentFile.Close(); err != nil {
-			return fmt.Errorf("close file: %w", err)
+	return finishFile(file, out, w.writeContent(out, f, references))
+}
+
+// finishFile flushes out and closes file, keeping the first error.
+func finishFile(file *os.File, out *bufio.Writer, err error) error {
+	if err == nil {
+		if err = out.Flush(); err != nil {
+			err = fmt.Errorf("flush writer: %w", err)
 		}
 	}
+	if closeErr := file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("close file: %w", closeErr)
+	}
+	return err
+}
 
-	// Create new file
-	filename := fmt.Sprintf("%s_%s.md", w.sanitizedName, monthKey)
-	filePath := filepath.Join(w.outputDir, filename)
+// writeContent writes the messages of a file to out, followed by
+// references and the footer, and records the state of the file.
+func (w *Writer) writeContent(out *bufio.Writer, f *outputFile, references []string) error {
+	sortRecords(f.buffer)
+	sources := []*recordSource{{buffer: f.buffer}}
+	for _, seg := range f.segments {
+		sources = append(sources, &recordSource{
+			reader: bufio.NewReader(io.NewSectionReader(w.spool, seg.offset, seg.size)),
+		})
+	}
+	if err := mergeRecords(sources, func(r record) error {
+		_, err := out.WriteString(r.text + "\n\n")
+		return err
+	}); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
 
-	file, err := os.Create(filePath)
+	for _, ref := range references {
+		if _, err := out.WriteString(ref); err != nil {
+			return fmt.Errorf("write references: %w", err)
+		}
+	}
+	footer, err := writeFrame(out, w.footer, f)
 	if err != nil {
-		return fmt.Errorf("create file %s: %w", filename, err)
+		return err
 	}
+	f.state = &FileState{Period: f.period, Start: f.start, References: references, Footer: footer}
+	return nil
+}
 
-	w.currentFile = file
-	w.currentWriter = bufio.NewWriter(file)
-	w.currentMonth = monthKey
-	w.stats[monthKey] = 0
-	w.fileCount++
+// writeFrame writes a header or footer of a file and returns its text.
+func writeFrame(out *bufio.Writer, render func(period string, start time.Time) (string, error), f *outputFile) (string, error) {
+	if render == nil {
+		return "", nil
+	}
+	text, err := render(f.period, f.start)
+	if err != nil {
+		return "", err
+	}
+	if _, err := out.WriteString(text); err != nil {
+		return "", fmt.Errorf("write frame: %w", err)
+	}
+	return text, nil
+}
 
-	return nil
+// referenceLabel returns the label of a link definition "[label]: url".
+func referenceLabel(ref string) string {
+	label, _, _ := strings.Cut(strings.TrimPrefix(ref, "["), "]: ")
+	return label
 }
 
-// getMonthKey generates the month key (e.g., "january_2024").
-func getMonthKey(t time.Time) string {
-	month := strings.ToLower(monthNames[t.Month()])
+// getMonthKey generates the month key (e.g., "january_2024"). name names
+// the month, nil means English.
+func getMonthKey(t time.Time, name func(time.Month) string) string {
+	month := monthNames[t.Month()]
+	if name != nil {
+		month = name(t.Month())
+	}
+	month = strings.ToLower(month)
 	year := t.Year()
 	return fmt.Sprintf("%s_%d", month, year)
 }
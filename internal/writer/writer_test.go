@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/grigoriizhovtun/tg2md/internal/locale"
 )
 
 func TestWriter_CreatesSanitizedDirectory(t *testing.T) {
@@ -127,6 +129,123 @@ func TestWriter_SwitchesFilesOnMonthChange(t *testing.T) {
 	}
 }
 
+func TestWriter_WritesReferencesPerFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	jan := time.Date(2024, time.January, 15, 14, 30, 0, 0, time.UTC)
+	if err := w.WriteMessage("[2024-01-15 14:30] Иван: [docs][1] и [spec][2]", jan); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	w.AddReference("1", "https://go.dev/doc")
+	w.AddReference("2", "https://go.dev/ref/spec")
+	if err := w.WriteMessage("[2024-01-15 14:31] Мария: снова [docs][1]", jan); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	w.AddReference("1", "https://go.dev/doc")
+
+	feb := time.Date(2024, time.February, 10, 10, 0, 0, 0, time.UTC)
+	if err := w.WriteMessage("[2024-02-10 10:00] Мария: [docs][1]", feb); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	w.AddReference("1", "https://go.dev/doc")
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	janContent, _ := os.ReadFile(filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md"))
+	wantJan := "[2024-01-15 14:30] Иван: [docs][1] и [spec][2]\n\n" +
+		"[2024-01-15 14:31] Мария: снова [docs][1]\n\n" +
+		"[1]: https://go.dev/doc\n" +
+		"[2]: https://go.dev/ref/spec\n"
+	if string(janContent) != wantJan {
+		t.Errorf("January file = %q, want %q", janContent, wantJan)
+	}
+
+	febContent, _ := os.ReadFile(filepath.Join(tempDir, "Test_Chat", "Test_Chat_february_2024.md"))
+	wantFeb := "[2024-02-10 10:00] Мария: [docs][1]\n\n[1]: https://go.dev/doc\n"
+	if string(febContent) != wantFeb {
+		t.Errorf("February file = %q, want %q", febContent, wantFeb)
+	}
+}
+
+func TestWriter_LocatesMessages(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	jan := time.Date(2024, time.January, 15, 14, 30, 0, 0, time.UTC)
+	feb := time.Date(2024, time.February, 10, 10, 0, 0, 0, time.UTC)
+	for _, m := range []struct {
+		id int64
+		ts time.Time
+	}{{1, jan}, {2, jan}, {4, jan}, {5, feb}, {6, feb}} {
+		if err := w.WriteMessage("message", m.ts); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+		w.MarkMessage(m.id)
+	}
+
+	janFile := filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md")
+	febFile := filepath.Join(tempDir, "Test_Chat", "Test_Chat_february_2024.md")
+	for _, tt := range []struct {
+		id   int64
+		want string
+	}{{1, janFile}, {4, janFile}, {5, febFile}, {6, febFile}} {
+		if got, ok := w.Locate(tt.id); !ok || got != tt.want {
+			t.Errorf("Locate(%d) = %q, %v, want %q", tt.id, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := w.Locate(7); ok {
+		t.Error("Locate(7) should not find an unwritten message")
+	}
+}
+
+func TestWriter_WritesHeaderAndFooter(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	w.SetFrame(func(period string, start time.Time) (string, error) {
+		return "# " + period + start.Format(" 02") + "\n\n", nil
+	}, func(period string, start time.Time) (string, error) {
+		return "-- " + period + "\n", nil
+	})
+
+	jan := time.Date(2024, time.January, 15, 14, 30, 0, 0, time.UTC)
+	feb := time.Date(2024, time.February, 10, 10, 0, 0, 0, time.UTC)
+	if err := w.WriteMessage("январь", jan); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if err := w.WriteMessage("февраль", feb); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	janContent, _ := os.ReadFile(filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md"))
+	if want := "# january_2024 15\n\nянварь\n\n-- january_2024\n"; string(janContent) != want {
+		t.Errorf("January file = %q, want %q", janContent, want)
+	}
+	febContent, _ := os.ReadFile(filepath.Join(tempDir, "Test_Chat", "Test_Chat_february_2024.md"))
+	if want := "# february_2024 10\n\nфевраль\n\n-- february_2024\n"; string(febContent) != want {
+		t.Errorf("February file = %q, want %q", febContent, want)
+	}
+}
+
 func TestWriter_GetStats_ReturnsCorrectCounts(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -252,10 +371,254 @@ func TestGetMonthKey(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := getMonthKey(tt.time)
+			result := getMonthKey(tt.time, nil)
 			if result != tt.expected {
 				t.Errorf("getMonthKey() = %q, want %q", result, tt.expected)
 			}
 		})
 	}
 }
+
+func TestWriter_MonthNames(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New(tempDir, "Чат")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := w.SetSplit(SplitOptions{Split: SplitMonth, MonthName: locale.Default().Month}); err != nil {
+		t.Fatalf("SetSplit failed: %v", err)
+	}
+
+	if err := w.WriteMessage("сообщение", time.Date(2024, time.May, 9, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "Чат", "Чат_май_2024.md")); err != nil {
+		t.Errorf("file with Russian month name not created: %v", err)
+	}
+}
+
+func TestWriter_RevisitedMonthKeepsEarlierMessages(t *testing.T) {
+	for _, budget := range []int{DefaultSortBudget, 1} {
+		tempDir := t.TempDir()
+
+		w, err := New(tempDir, "Test Chat")
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		w.SetSortBudget(NewSortBudget(budget))
+
+		day := func(month time.Month, d, hour int) time.Time {
+			return time.Date(2024, month, d, hour, 0, 0, 0, time.UTC)
+		}
+		for _, m := range []struct {
+			text string
+			ts   time.Time
+		}{
+			{"jan 10", day(time.January, 10, 9)},
+			{"feb 1", day(time.February, 1, 9)},
+			{"jan 5", day(time.January, 5, 9)},
+			{"jan 20 first", day(time.January, 20, 9)},
+			{"jan 20 second", day(time.January, 20, 9)},
+			{"feb 2", day(time.February, 2, 9)},
+		} {
+			if err := w.WriteMessage(m.text, m.ts); err != nil {
+				t.Fatalf("WriteMessage failed: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		dir := filepath.Join(tempDir, "Test_Chat")
+		janContent, _ := os.ReadFile(filepath.Join(dir, "Test_Chat_january_2024.md"))
+		if want := "jan 5\n\njan 10\n\njan 20 first\n\njan 20 second\n\n"; string(janContent) != want {
+			t.Errorf("budget %d: January file = %q, want %q", budget, janContent, want)
+		}
+		febContent, _ := os.ReadFile(filepath.Join(dir, "Test_Chat_february_2024.md"))
+		if want := "feb 1\n\nfeb 2\n\n"; string(febContent) != want {
+			t.Errorf("budget %d: February file = %q, want %q", budget, febContent, want)
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 2 {
+			t.Errorf("budget %d: directory has %d entries, want only the month files", budget, len(entries))
+		}
+		if w.GetStats()["january_2024"] != 4 {
+			t.Errorf("budget %d: January count = %d, want 4", budget, w.GetStats()["january_2024"])
+		}
+	}
+}
+
+func TestWriter_SharedSortBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	group, err := New(tempDir, "Group")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	topic, err := New(filepath.Join(tempDir, "Group"), "Topic")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	budget := NewSortBudget(3 * (recordOverhead + 10))
+	group.SetSortBudget(budget)
+	topic.SetSortBudget(budget)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, time.January, d, 9, 0, 0, 0, time.UTC)
+	}
+	for _, m := range []struct {
+		text string
+		day  int
+	}{{"group 3", 3}, {"group 1", 1}, {"group 2", 2}} {
+		if err := group.WriteMessage(m.text, day(m.day)); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+	}
+	// The topic's first message spills the group's buffer
+	if err := topic.WriteMessage("topic 1", day(1)); err != nil {
+		t.Fatalf("WriteMessage failed: %v", err)
+	}
+	if budget.used > budget.limit {
+		t.Errorf("used = %d, want at most %d", budget.used, budget.limit)
+	}
+	if group.spool == nil {
+		t.Error("group buffer was not spilled")
+	}
+
+	for _, w := range []*Writer{topic, group} {
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	content, _ := os.ReadFile(filepath.Join(tempDir, "Group", "Group_january_2024.md"))
+	if want := "group 1\n\ngroup 2\n\ngroup 3\n\n"; string(content) != want {
+		t.Errorf("group file = %q, want %q", content, want)
+	}
+	content, _ = os.ReadFile(filepath.Join(tempDir, "Group", "Topic", "Topic_january_2024.md"))
+	if want := "topic 1\n\n"; string(content) != want {
+		t.Errorf("topic file = %q, want %q", content, want)
+	}
+}
+
+func TestWriter_FrameUsesEarliestMessage(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	w.SetFrame(func(period string, start time.Time) (string, error) {
+		return start.Format("02") + "\n", nil
+	}, nil)
+
+	w.WriteMessage("late", time.Date(2024, time.January, 20, 0, 0, 0, 0, time.UTC))
+	w.WriteMessage("early", time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md"))
+	if want := "03\nearly\n\nlate\n\n"; string(content) != want {
+		t.Errorf("January file = %q, want %q", content, want)
+	}
+}
+
+// runResumed writes messages with a framed writer resuming files, and
+// returns the state of the files it wrote.
+func runResumed(t *testing.T, dir string, resumed map[string]FileState, messages map[string]time.Time) map[string]FileState {
+	t.Helper()
+	w, err := New(dir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	w.SetFrame(func(period string, start time.Time) (string, error) {
+		return "# " + period + start.Format(" 02") + "\n\n", nil
+	}, func(period string, start time.Time) (string, error) {
+		return "-- " + period + start.Format(" 02") + "\n", nil
+	})
+	w.Resume(resumed)
+	for text, ts := range messages {
+		if err := w.WriteMessage(text, ts); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+		w.AddReference(text, "https://example.com/"+text)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return w.Files()
+}
+
+func TestWriter_ResumeAppendsToFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	jan := filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md")
+	feb := filepath.Join(tempDir, "Test_Chat", "Test_Chat_february_2024.md")
+
+	files := runResumed(t, tempDir, nil, map[string]time.Time{
+		"a": time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC),
+		"b": time.Date(2024, time.February, 10, 10, 0, 0, 0, time.UTC),
+	})
+	if len(files) != 2 || files[jan].Footer != "-- january_2024 15\n" {
+		t.Fatalf("Files() = %+v", files)
+	}
+
+	// A file without new messages is left as edited by hand
+	edited := "# january_2024 15\n\nзаметка\n"
+	os.WriteFile(jan, []byte(edited), 0644)
+
+	// New messages go before the link definitions and footer, an earlier
+	// one moves the footer start
+	runResumed(t, tempDir, files, map[string]time.Time{
+		"c": time.Date(2024, time.February, 5, 10, 0, 0, 0, time.UTC),
+	})
+
+	if content, _ := os.ReadFile(jan); string(content) != edited {
+		t.Errorf("untouched file changed: %q", content)
+	}
+	want := "# february_2024 10\n\nb\n\nc\n\n[b]: https://example.com/b\n[c]: https://example.com/c\n-- february_2024 05\n"
+	if content, _ := os.ReadFile(feb); string(content) != want {
+		t.Errorf("appended file = %q, want %q", content, want)
+	}
+}
+
+func TestWriter_ResumeKeepsTextAddedAtEnd(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "Test_Chat", "Test_Chat_january_2024.md")
+
+	files := runResumed(t, tempDir, nil, map[string]time.Time{
+		"a": time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC),
+	})
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("заметка")
+	f.Close()
+
+	runResumed(t, tempDir, files, map[string]time.Time{
+		"b": time.Date(2024, time.January, 20, 10, 0, 0, 0, time.UTC),
+	})
+
+	want := "# january_2024 15\n\na\n\n[a]: https://example.com/a\n-- january_2024 15\nзаметка\n\n" +
+		"b\n\n[b]: https://example.com/b\n-- january_2024 15\n"
+	if content, _ := os.ReadFile(path); string(content) != want {
+		t.Errorf("appended file = %q, want %q", content, want)
+	}
+}
+
+func TestWriter_MarkWritten(t *testing.T) {
+	tempDir := t.TempDir()
+	w, err := New(tempDir, "Test Chat")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer w.Close()
+
+	w.MarkWritten(5, time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC))
+	path, ok := w.Locate(5)
+	if want := filepath.Join(tempDir, "Test_Chat", "Test_Chat_march_2024.md"); !ok || path != want {
+		t.Errorf("Locate(5) = %q, %v, want %q", path, ok, want)
+	}
+}